@@ -0,0 +1,158 @@
+package adapter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp"
+	"github.com/pkg/errors"
+)
+
+// ACLBindingRegistry keeps each binding as a Redis ACL user named after the
+// binding ID, managed by the admin user, on every node of the instance. The
+// users are saved to the aclfile of each node, so they survive restarts.
+type ACLBindingRegistry struct {
+	Timeout time.Duration
+	Retries int
+}
+
+// BindingExists reports whether any node has the binding's user, so that
+// bindings left on some nodes by a failed unbind can still be deleted.
+func (r ACLBindingRegistry) BindingExists(instance RedisInstance, bindingID string) (bool, error) {
+	var exists bool
+	for _, node := range instance.nodeInstances() {
+		err := r.withAdminClient(node, func(client *resp.Client) error {
+			user, err := client.Do("ACL", "GETUSER", BindingUsername(bindingID))
+			if err != nil {
+				return errors.Wrap(err, "ACL GETUSER failed")
+			}
+			exists = exists || !user.IsNil
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return exists, nil
+}
+
+// CreateBinding creates the binding's user on every node, and brings the
+// commands the other binding users are denied up to date with the plan. When
+// a node fails, the user is deleted from the nodes it was already created on.
+func (r ACLBindingRegistry) CreateBinding(instance RedisInstance, bindingID, password string) error {
+	username := BindingUsername(bindingID)
+	rules := bindingUserCommandRules(instance.DeniedCommands)
+	args := append([]string{"ACL", "SETUSER", username, "reset", "on", ">" + password, "~*", "&*"}, rules...)
+
+	var created []RedisInstance
+	for _, node := range instance.nodeInstances() {
+		err := r.withAdminClient(node, func(client *resp.Client) error {
+			if _, err := client.Do(args...); err != nil {
+				return errors.Wrap(err, "ACL SETUSER failed")
+			}
+			if err := reconcileBindingUsers(client, username, rules); err != nil {
+				return err
+			}
+			return saveUsers(client)
+		})
+		if err != nil {
+			for _, createdNode := range created {
+				r.deleteUser(createdNode, bindingID)
+			}
+			return errors.Wrapf(err, "could not create binding on %s", node.Hosts[0])
+		}
+		created = append(created, node)
+	}
+	return nil
+}
+
+// bindingUserCommandRules grant binding users every command but the
+// administrative ones and those the plan denies. Starting from +@all lets the
+// rules replace those of existing users.
+func bindingUserCommandRules(deniedCommands []string) []string {
+	rules := []string{"+@all", "-@admin"}
+	for _, command := range deniedCommands {
+		rules = append(rules, "-"+strings.ToLower(command))
+	}
+	return rules
+}
+
+// reconcileBindingUsers applies rules to the binding users other than
+// username, which were created when the plan denied other commands. Plan
+// changes only reach the Redis servers on deploy, which the adapter takes no
+// part in, so existing binding users are brought up to date on the next bind
+// or unbind of the instance instead.
+func reconcileBindingUsers(client *resp.Client, username string, rules []string) error {
+	users, err := client.Do("ACL", "LIST")
+	if err != nil {
+		return errors.Wrap(err, "ACL LIST failed")
+	}
+	for _, user := range users.Elems {
+		// Each user is described as "user <name> <rules...>"
+		fields := strings.Fields(user.Str)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], BindingUsernamePrefix) || fields[1] == username {
+			continue
+		}
+		if _, err := client.Do(append([]string{"ACL", "SETUSER", fields[1]}, rules...)...); err != nil {
+			return errors.Wrapf(err, "could not update the commands of %s", fields[1])
+		}
+	}
+	return nil
+}
+
+// DeleteBinding deletes the binding's user from every node it can, and brings
+// the commands the other binding users are denied up to date with the plan.
+// It fails if any node could not be reached.
+func (r ACLBindingRegistry) DeleteBinding(instance RedisInstance, bindingID string) error {
+	rules := bindingUserCommandRules(instance.DeniedCommands)
+
+	var failures []string
+	for _, node := range instance.nodeInstances() {
+		err := r.withAdminClient(node, func(client *resp.Client) error {
+			if _, err := client.Do("ACL", "DELUSER", BindingUsername(bindingID)); err != nil {
+				return errors.Wrap(err, "ACL DELUSER failed")
+			}
+			if err := reconcileBindingUsers(client, "", rules); err != nil {
+				return err
+			}
+			return saveUsers(client)
+		})
+		if err != nil {
+			failures = append(failures, node.Hosts[0]+": "+err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("could not delete binding on %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (r ACLBindingRegistry) deleteUser(instance RedisInstance, bindingID string) error {
+	return r.withAdminClient(instance, func(client *resp.Client) error {
+		if _, err := client.Do("ACL", "DELUSER", BindingUsername(bindingID)); err != nil {
+			return errors.Wrap(err, "ACL DELUSER failed")
+		}
+		return saveUsers(client)
+	})
+}
+
+// saveUsers writes the node's users to its aclfile, without which they would
+// be lost when Redis restarts.
+func saveUsers(client *resp.Client) error {
+	_, err := client.Do("ACL", "SAVE")
+	return errors.Wrap(err, "ACL SAVE failed")
+}
+
+func (r ACLBindingRegistry) withAdminClient(instance RedisInstance, f func(client *resp.Client) error) error {
+	if len(instance.Hosts) == 0 {
+		return errors.New("no hosts to connect to")
+	}
+
+	client, err := connectToInstance(instance, "", instance.AdminPassword, r.Timeout, r.Retries)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to the service instance")
+	}
+	defer client.Close()
+
+	return f(client)
+}
@@ -0,0 +1,134 @@
+package adapter_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp/resptest"
+)
+
+var _ = Describe("ACLBindingRegistry", func() {
+	var (
+		server   *resptest.Server
+		instance adapter.RedisInstance
+		registry adapter.ACLBindingRegistry
+	)
+
+	BeforeEach(func() {
+		server = resptest.NewServer("admin-password")
+		instance = adapter.RedisInstance{
+			Hosts:         []string{server.Host()},
+			Port:          server.Port(),
+			AdminPassword: "admin-password",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("creates, finds and deletes ACL users for bindings", func() {
+		exists, err := registry.BindingExists(instance, "some-binding")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+
+		Expect(registry.CreateBinding(instance, "some-binding", "binding-password")).To(Succeed())
+		Expect(server.Users()).To(Equal([]string{"binding-some-binding"}))
+		Expect(server.SavedUsers()).To(Equal([]string{"binding-some-binding"}))
+
+		exists, err = registry.BindingExists(instance, "some-binding")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+
+		Expect(registry.DeleteBinding(instance, "some-binding")).To(Succeed())
+		Expect(server.Users()).To(BeEmpty())
+		Expect(server.SavedUsers()).To(BeEmpty())
+	})
+
+	It("denies binding users the instance's denied commands", func() {
+		instance.DeniedCommands = []string{"CONFIG", "FLUSHALL"}
+
+		Expect(registry.CreateBinding(instance, "some-binding", "binding-password")).To(Succeed())
+		Expect(server.Rules("binding-some-binding")).To(Equal([]string{
+			"reset", "on", ">binding-password", "~*", "&*", "+@all", "-@admin", "-config", "-flushall",
+		}))
+	})
+
+	It("denies existing binding users the commands the plan denies now", func() {
+		Expect(registry.CreateBinding(instance, "old-binding", "old-password")).To(Succeed())
+
+		instance.DeniedCommands = []string{"KEYS"}
+		Expect(registry.CreateBinding(instance, "new-binding", "new-password")).To(Succeed())
+		Expect(server.Rules("binding-old-binding")).To(Equal([]string{"+@all", "-@admin", "-keys"}))
+		Expect(server.Rules("binding-new-binding")).To(ContainElement("-keys"))
+	})
+
+	It("updates the commands existing binding users are denied on unbind", func() {
+		instance.DeniedCommands = []string{"KEYS"}
+		Expect(registry.CreateBinding(instance, "old-binding", "old-password")).To(Succeed())
+		Expect(registry.CreateBinding(instance, "other-binding", "other-password")).To(Succeed())
+
+		instance.DeniedCommands = nil
+		Expect(registry.DeleteBinding(instance, "other-binding")).To(Succeed())
+		Expect(server.Rules("binding-old-binding")).To(Equal([]string{"+@all", "-@admin"}))
+		Expect(server.Users()).To(Equal([]string{"binding-old-binding"}))
+	})
+
+	Describe("on instances with several nodes", func() {
+		var otherNode *resptest.Server
+
+		BeforeEach(func() {
+			otherNode = resptest.NewServerAt(fmt.Sprintf("127.0.0.2:%d", server.Port()), "admin-password")
+			instance.Nodes = []string{"127.0.0.1", "127.0.0.2"}
+		})
+
+		AfterEach(func() {
+			otherNode.Close()
+		})
+
+		It("creates and deletes the binding user on every node", func() {
+			Expect(registry.CreateBinding(instance, "some-binding", "binding-password")).To(Succeed())
+			Expect(server.Users()).To(Equal([]string{"binding-some-binding"}))
+			Expect(otherNode.Users()).To(Equal([]string{"binding-some-binding"}))
+
+			Expect(registry.DeleteBinding(instance, "some-binding")).To(Succeed())
+			Expect(server.Users()).To(BeEmpty())
+			Expect(otherNode.Users()).To(BeEmpty())
+		})
+
+		It("finds bindings left on some of the nodes", func() {
+			otherNode.AddUser("binding-some-binding", "binding-password")
+
+			exists, err := registry.BindingExists(instance, "some-binding")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+		})
+
+		It("removes the binding user again when a node fails", func() {
+			otherNode.Close()
+
+			err := registry.CreateBinding(instance, "some-binding", "binding-password")
+			Expect(err).To(MatchError(ContainSubstring("could not create binding on 127.0.0.2")))
+			Expect(server.Users()).To(BeEmpty())
+		})
+	})
+
+	It("connects over TLS when the instance has a TLS port", func() {
+		tlsServer := resptest.NewTLSServer("admin-password")
+		defer tlsServer.Close()
+		instance.Port = 1
+		instance.TLSPort = tlsServer.Port()
+		instance.CACert = tlsServer.CACert
+
+		Expect(registry.CreateBinding(instance, "some-binding", "binding-password")).To(Succeed())
+		Expect(tlsServer.Users()).To(Equal([]string{"binding-some-binding"}))
+	})
+
+	It("fails when the admin password is wrong", func() {
+		instance.AdminPassword = "wrong"
+		_, err := registry.BindingExists(instance, "some-binding")
+		Expect(err).To(MatchError(ContainSubstring("could not connect to the service instance: WRONGPASS")))
+	})
+})
@@ -0,0 +1,239 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+
+	DefaultAuditLogMaxSizeMB = 10
+	DefaultAuditLogMaxFiles  = 5
+)
+
+var secretParameterKeys = []string{"password", "secret", "token", "private_key", "cert"}
+
+// AuditRecord describes one adapter invocation.
+type AuditRecord struct {
+	Timestamp  string                 `json:"timestamp"`
+	Operation  string                 `json:"operation"`
+	Deployment string                 `json:"deployment,omitempty"`
+	BindingID  string                 `json:"binding_id,omitempty"`
+	Plan       string                 `json:"plan,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Changes    *ManifestChanges       `json:"changes,omitempty"`
+	Outcome    string                 `json:"outcome"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// ManifestChanges is what a generated manifest changes compared to the
+// previous one.
+type ManifestChanges struct {
+	Releases        []ReleaseChange     `json:"releases,omitempty"`
+	PasswordRotated bool                `json:"password_rotated"`
+	VMExtensions    *VMExtensionsChange `json:"vm_extensions,omitempty"`
+	PlanChanged     bool                `json:"plan_changed"`
+	PreviousPlan    string              `json:"previous_plan,omitempty"`
+}
+
+type ReleaseChange struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type VMExtensionsChange struct {
+	From []string `json:"from"`
+	To   []string `json:"to"`
+}
+
+type AuditLog interface {
+	Record(record AuditRecord) error
+}
+
+// FileAuditLog appends records as JSON lines to Path. Once the file would
+// grow past MaxSizeMB it is rotated to Path.1, Path.1 to Path.2 and so on,
+// keeping at most MaxFiles old files.
+type FileAuditLog struct {
+	Path      string
+	MaxSizeMB int
+	MaxFiles  int
+	Now       func() time.Time
+}
+
+func (l FileAuditLog) Record(record AuditRecord) error {
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+	record.Timestamp = now().UTC().Format(time.RFC3339Nano)
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := l.rotateIfNeeded(int64(len(line))); err != nil {
+		return fmt.Errorf("could not rotate audit log %s: %s", l.Path, err)
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open audit log %s: %s", l.Path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("could not write audit log %s: %s", l.Path, err)
+	}
+	return nil
+}
+
+func (l FileAuditLog) rotateIfNeeded(lineSize int64) error {
+	maxSizeMB := l.MaxSizeMB
+	if maxSizeMB == 0 {
+		maxSizeMB = DefaultAuditLogMaxSizeMB
+	}
+	maxFiles := l.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = DefaultAuditLogMaxFiles
+	}
+
+	info, err := os.Stat(l.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+lineSize <= int64(maxSizeMB)*1024*1024 {
+		return nil
+	}
+
+	if err := os.Remove(fmt.Sprintf("%s.%d", l.Path, maxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", l.Path, i), fmt.Sprintf("%s.%d", l.Path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(l.Path, l.Path+".1")
+}
+
+func auditOutcome(record AuditRecord, err error) AuditRecord {
+	record.Outcome = AuditOutcomeSuccess
+	if err != nil {
+		record.Outcome = AuditOutcomeFailure
+		record.Error = RedactSecrets(ErrorDetail(err))
+	}
+	return record
+}
+
+// redactParameters copies the user supplied parameters, hiding values whose
+// key suggests a secret, including in nested parameters such as redis_config.
+func redactParameters(params map[string]interface{}) map[string]interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		redacted[key] = redactParameterValue(value)
+		for _, secretKey := range secretParameterKeys {
+			if strings.Contains(strings.ToLower(key), secretKey) {
+				redacted[key] = redactedValue
+			}
+		}
+	}
+	return redacted
+}
+
+func redactParameterValue(value interface{}) interface{} {
+	switch nested := value.(type) {
+	case map[string]interface{}:
+		if len(nested) == 0 {
+			return nested
+		}
+		return redactParameters(nested)
+	case []interface{}:
+		items := make([]interface{}, len(nested))
+		for i, item := range nested {
+			items[i] = redactParameterValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+func manifestChanges(params serviceadapter.GenerateManifestParams, manifest bosh.BoshManifest, redisInstanceGroupName string) *ManifestChanges {
+	if params.PreviousManifest == nil {
+		return nil
+	}
+	previous := *params.PreviousManifest
+
+	changes := &ManifestChanges{
+		Releases:    releaseChanges(previous.Releases, manifest.Releases),
+		PlanChanged: params.PreviousPlan != nil && !reflect.DeepEqual(*params.PreviousPlan, params.Plan),
+	}
+	if previousValues, ok := params.RequestParams["previous_values"].(map[string]interface{}); ok {
+		changes.PreviousPlan, _ = previousValues["plan_id"].(string)
+		if changes.PreviousPlan != "" && changes.PreviousPlan != planID(params.RequestParams) {
+			changes.PlanChanged = true
+		}
+	}
+
+	previousPassword, _ := manifestRedisProperties(previous)["password"].(string)
+	password, _ := manifestRedisProperties(manifest)["password"].(string)
+	changes.PasswordRotated = previousPassword != "" && previousPassword != password
+
+	previousExtensions := instanceGroupVMExtensions(previous, redisInstanceGroupName)
+	extensions := instanceGroupVMExtensions(manifest, redisInstanceGroupName)
+	if !reflect.DeepEqual(previousExtensions, extensions) {
+		changes.VMExtensions = &VMExtensionsChange{From: previousExtensions, To: extensions}
+	}
+	return changes
+}
+
+func releaseChanges(previous, current []bosh.Release) []ReleaseChange {
+	versions := map[string]*ReleaseChange{}
+	for _, release := range previous {
+		versions[release.Name] = &ReleaseChange{Name: release.Name, From: release.Version}
+	}
+	for _, release := range current {
+		if change, ok := versions[release.Name]; ok {
+			change.To = release.Version
+		} else {
+			versions[release.Name] = &ReleaseChange{Name: release.Name, To: release.Version}
+		}
+	}
+
+	var changes []ReleaseChange
+	for _, change := range versions {
+		if change.From != change.To {
+			changes = append(changes, *change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func instanceGroupVMExtensions(manifest bosh.BoshManifest, name string) []string {
+	for _, instanceGroup := range manifest.InstanceGroups {
+		if instanceGroup.Name == name {
+			return append([]string{}, instanceGroup.VMExtensions...)
+		}
+	}
+	return []string{}
+}
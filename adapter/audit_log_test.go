@@ -0,0 +1,170 @@
+package adapter_test
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Audit log", func() {
+	Describe("FileAuditLog", func() {
+		var (
+			dir      string
+			auditLog adapter.FileAuditLog
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "audit-log")
+			Expect(err).NotTo(HaveOccurred())
+			auditLog = adapter.FileAuditLog{
+				Path:      filepath.Join(dir, "audit.log"),
+				MaxSizeMB: 1,
+				MaxFiles:  2,
+				Now:       func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) },
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("appends records as JSON lines", func() {
+			Expect(auditLog.Record(adapter.AuditRecord{Operation: "create-binding", Outcome: adapter.AuditOutcomeSuccess})).To(Succeed())
+			Expect(auditLog.Record(adapter.AuditRecord{Operation: "delete-binding", Outcome: adapter.AuditOutcomeFailure, Error: "oops"})).To(Succeed())
+
+			contents, err := ioutil.ReadFile(auditLog.Path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(
+				`{"timestamp":"2026-10-18T12:00:00Z","operation":"create-binding","outcome":"success"}` + "\n" +
+					`{"timestamp":"2026-10-18T12:00:00Z","operation":"delete-binding","outcome":"failure","error":"oops"}` + "\n",
+			))
+		})
+
+		It("rotates the log once it is full", func() {
+			full := strings.Repeat("x", 1024*1024) + "\n"
+			Expect(ioutil.WriteFile(auditLog.Path, []byte(full), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(auditLog.Path+".1", []byte("first"), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(auditLog.Path+".2", []byte("oldest"), 0600)).To(Succeed())
+
+			Expect(auditLog.Record(adapter.AuditRecord{Operation: "create-binding", Outcome: adapter.AuditOutcomeSuccess})).To(Succeed())
+
+			Expect(ioutil.ReadFile(auditLog.Path)).To(ContainSubstring("create-binding"))
+			Expect(ioutil.ReadFile(auditLog.Path + ".1")).To(Equal([]byte(full)))
+			Expect(ioutil.ReadFile(auditLog.Path + ".2")).To(Equal([]byte("first")))
+			Expect(auditLog.Path + ".3").NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("generating manifests", func() {
+		var (
+			auditLog          *fakeAuditLog
+			manifestGenerator adapter.ManifestGenerator
+			plan              serviceadapter.Plan
+		)
+
+		BeforeEach(func() {
+			auditLog = &fakeAuditLog{}
+			manifestGenerator = adapter.ManifestGenerator{
+				StderrLogger: log.New(io.Discard, "", 0),
+				Config:       adapter.Config{RedisInstanceGroupName: adapter.RedisJobName},
+				AuditLog:     auditLog,
+			}
+			plan = serviceadapter.Plan{
+				Properties: serviceadapter.Properties{"persistence": true},
+				InstanceGroups: []serviceadapter.InstanceGroup{{
+					Name:         adapter.RedisJobName,
+					VMType:       "small",
+					VMExtensions: []string{"public-ip"},
+					Networks:     []string{"default"},
+					Instances:    1,
+					AZs:          []string{"z1"},
+				}},
+			}
+		})
+
+		releases := serviceadapter.ServiceReleases{{
+			Name:    "some-release-name",
+			Version: "5",
+			Jobs:    []string{adapter.RedisJobName},
+		}}
+
+		It("records what changed compared to the previous manifest", func() {
+			oldManifest := createDefaultOldManifest()
+			oldPlan := plan
+			oldPlan.Properties = serviceadapter.Properties{"persistence": false}
+			requestParams := map[string]interface{}{
+				"plan_id":         "new-plan",
+				"previous_values": map[string]interface{}{"plan_id": "old-plan"},
+				"parameters": map[string]interface{}{
+					"maxclients":             float64(100),
+					adapter.ManagedSecretKey: "hunter2",
+				},
+			}
+
+			_, err := generateManifest(manifestGenerator, releases, plan, requestParams, &oldManifest, &oldPlan, nil, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(auditLog.records).To(HaveLen(1))
+			record := auditLog.records[0]
+			Expect(record.Operation).To(Equal("generate-manifest"))
+			Expect(record.Deployment).To(Equal("some-instance-id"))
+			Expect(record.Plan).To(Equal("new-plan"))
+			Expect(record.Outcome).To(Equal(adapter.AuditOutcomeSuccess))
+			Expect(record.Parameters).To(Equal(map[string]interface{}{
+				"maxclients":             float64(100),
+				adapter.ManagedSecretKey: "<redacted>",
+			}))
+			Expect(record.Changes).To(Equal(&adapter.ManifestChanges{
+				Releases:        []adapter.ReleaseChange{{Name: "some-release-name", From: "4", To: "5"}},
+				PasswordRotated: false,
+				VMExtensions:    &adapter.VMExtensionsChange{From: []string{}, To: []string{"public-ip"}},
+				PlanChanged:     true,
+				PreviousPlan:    "old-plan",
+			}))
+
+			recordJSON, err := json.Marshal(record)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(recordJSON)).NotTo(ContainSubstring("hunter2"))
+		})
+
+		It("records no changes for new instances", func() {
+			_, err := generateManifest(manifestGenerator, releases, plan, map[string]interface{}{}, nil, nil, nil, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(auditLog.records[0].Changes).To(BeNil())
+		})
+
+		It("records failures", func() {
+			_, err := generateManifest(manifestGenerator, releases, plan, map[string]interface{}{
+				"parameters": map[string]interface{}{"foo": "bar"},
+			}, nil, nil, nil, nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(auditLog.records[0].Outcome).To(Equal(adapter.AuditOutcomeFailure))
+			Expect(auditLog.records[0].Error).To(ContainSubstring("unsupported parameter(s)"))
+		})
+
+		It("redacts secrets nested in parameters", func() {
+			_, err := generateManifest(manifestGenerator, releases, plan, map[string]interface{}{
+				"parameters": map[string]interface{}{
+					adapter.RedisConfigParameter: map[string]interface{}{"masterauth_password": "hunter2", "timeout": float64(10)},
+					"users":                      []interface{}{map[string]interface{}{"name": "app", "token": "hunter3"}},
+				},
+			}, nil, nil, nil, nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(auditLog.records[0].Parameters).To(Equal(map[string]interface{}{
+				adapter.RedisConfigParameter: map[string]interface{}{"masterauth_password": "<redacted>", "timeout": float64(10)},
+				"users":                      []interface{}{map[string]interface{}{"name": "app", "token": "<redacted>"}},
+			}))
+		})
+	})
+})
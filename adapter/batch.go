@@ -0,0 +1,100 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// maxBatchLineSize bounds one input line, which carries a whole previous
+// manifest.
+const maxBatchLineSize = 64 * 1024 * 1024
+
+// BatchResult is written as one JSON line per input line. Output is exactly
+// what generate-manifest prints for the broker.
+type BatchResult struct {
+	Line     int             `json:"line"`
+	ID       string          `json:"id,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Kind     ErrorKind       `json:"kind,omitempty"`
+	ExitCode int             `json:"exit_code"`
+}
+
+// batchInput is the JSON the broker passes to generate-manifest on stdin,
+// plus an optional id that is copied to the result to correlate them.
+type batchInput struct {
+	serviceadapter.InputParams
+	ID string `json:"id"`
+}
+
+// RunBatch reads newline delimited generate-manifest inputs from in and writes
+// a BatchResult for each to out as soon as it is generated. It returns how
+// many inputs failed; the error is only set if in or out fail.
+func RunBatch(generator serviceadapter.ManifestGenerator, in io.Reader, out io.Writer) (int, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchLineSize)
+	encoder := json.NewEncoder(out)
+
+	capturing := &capturingGenerator{generator: generator}
+	action := serviceadapter.NewGenerateManifestAction(capturing)
+
+	failed := 0
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		result := generateBatchResult(action, capturing, line, scanner.Bytes())
+		if result.Error != "" {
+			failed++
+		}
+		if err := encoder.Encode(result); err != nil {
+			return failed, err
+		}
+	}
+	return failed, scanner.Err()
+}
+
+func generateBatchResult(action *serviceadapter.GenerateManifestAction, capturing *capturingGenerator, line int, data []byte) BatchResult {
+	result := BatchResult{Line: line}
+
+	var input batchInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		result.Error = fmt.Sprintf("error unmarshalling input params JSON, error: %s", err)
+		result.ExitCode = serviceadapter.ErrorExitCode
+		return result
+	}
+	result.ID = input.ID
+
+	capturing.err = nil
+	output := &bytes.Buffer{}
+	if err := action.Execute(input.InputParams, output); err != nil {
+		if capturing.err != nil {
+			err = capturing.err
+		}
+		result.Error = ErrorDetail(err)
+		result.Kind = ErrorKindOf(err)
+		result.ExitCode = ExitCode(err)
+		return result
+	}
+	result.Output = json.RawMessage(output.Bytes())
+	return result
+}
+
+// capturingGenerator keeps the error GenerateManifest returned, which the
+// SDK replaces with the message shown to users.
+type capturingGenerator struct {
+	generator serviceadapter.ManifestGenerator
+	err       error
+}
+
+func (c *capturingGenerator) GenerateManifest(params serviceadapter.GenerateManifestParams) (serviceadapter.GenerateManifestOutput, error) {
+	output, err := c.generator.GenerateManifest(params)
+	c.err = err
+	return output, err
+}
@@ -0,0 +1,167 @@
+package adapter_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("RunBatch", func() {
+	var (
+		generator adapter.ManifestGenerator
+		params    serviceadapter.GenerateManifestParams
+	)
+
+	batchLine := func(id string, requestParams map[string]interface{}) string {
+		toJSON := func(v interface{}) string {
+			data, err := json.Marshal(v)
+			Expect(err).NotTo(HaveOccurred())
+			return string(data)
+		}
+		return toJSON(map[string]interface{}{
+			"id": id,
+			"generate_manifest": serviceadapter.GenerateManifestJSONParams{
+				ServiceDeployment: toJSON(params.ServiceDeployment),
+				Plan:              toJSON(params.Plan),
+				RequestParameters: toJSON(requestParams),
+				PreviousManifest:  "",
+				PreviousPlan:      "null",
+			},
+		})
+	}
+
+	readResults := func(out *bytes.Buffer) []adapter.BatchResult {
+		var results []adapter.BatchResult
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			var result adapter.BatchResult
+			Expect(json.Unmarshal(scanner.Bytes(), &result)).To(Succeed())
+			results = append(results, result)
+		}
+		return results
+	}
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{
+			Config:         config,
+			StderrLogger:   log.New(io.Writer(GinkgoWriter), "", 0),
+			ReleaseLookups: adapter.NewReleaseLookups(),
+		}
+
+		var err error
+		params, err = adapter.LoadRenderInputs(adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("writes one result per input line, in order", func() {
+		in := strings.Join([]string{
+			batchLine("first", map[string]interface{}{"parameters": map[string]interface{}{"maxclients": 10}}),
+			"",
+			batchLine("second", map[string]interface{}{"parameters": map[string]interface{}{"maxclients": 20}}),
+		}, "\n")
+		out := &bytes.Buffer{}
+
+		failed, err := adapter.RunBatch(generator, strings.NewReader(in), out)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failed).To(Equal(0))
+
+		results := readResults(out)
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Line).To(Equal(1))
+		Expect(results[0].ID).To(Equal("first"))
+		Expect(results[1].Line).To(Equal(3))
+		Expect(results[1].ID).To(Equal("second"))
+
+		var output serviceadapter.MarshalledGenerateManifest
+		Expect(json.Unmarshal(results[1].Output, &output)).To(Succeed())
+		Expect(output.ODBManagedSecrets).To(HaveKey(adapter.ManagedSecretKey))
+		Expect(output.Manifest).To(ContainSubstring("maxclients: 20"))
+	})
+
+	It("reports failures with their cause and carries on", func() {
+		in := strings.Join([]string{
+			batchLine("bad-params", map[string]interface{}{"parameters": map[string]interface{}{"foo": "bar"}}),
+			"{not json",
+			batchLine("good", map[string]interface{}{}),
+		}, "\n")
+		out := &bytes.Buffer{}
+
+		failed, err := adapter.RunBatch(generator, strings.NewReader(in), out)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failed).To(Equal(2))
+
+		results := readResults(out)
+		Expect(results).To(HaveLen(3))
+		Expect(results[0]).To(Equal(adapter.BatchResult{
+			Line:     1,
+			ID:       "bad-params",
+			Error:    "unsupported parameter(s) for this service plan: foo",
+			Kind:     adapter.UserErrorKind,
+			ExitCode: serviceadapter.ErrorExitCode,
+		}))
+		Expect(results[1].Error).To(ContainSubstring("error unmarshalling input params JSON"))
+		Expect(results[2].Error).To(BeEmpty())
+		Expect(results[2].Output).NotTo(BeEmpty())
+	})
+
+	It("shows operators the cause of operator errors", func() {
+		params.ServiceDeployment.Releases[0].Jobs = []string{adapter.HealthCheckErrandName}
+		out := &bytes.Buffer{}
+
+		failed, err := adapter.RunBatch(generator, strings.NewReader(batchLine("", map[string]interface{}{})), out)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failed).To(Equal(1))
+
+		results := readResults(out)
+		Expect(results[0].Kind).To(Equal(adapter.OperatorErrorKind))
+		Expect(results[0].Error).To(ContainSubstring("no release provided for job redis-server"))
+	})
+})
+
+var _ = Describe("ReleaseLookups", func() {
+	releases := serviceadapter.ServiceReleases{
+		{Name: "redis", Version: "4", Jobs: []string{"redis-server"}},
+		{Name: "other", Version: "1", Jobs: []string{"health-check"}},
+	}
+
+	It("finds the release providing a job", func() {
+		lookups := adapter.NewReleaseLookups()
+
+		release, err := lookups.ReleaseForJob("health-check", releases)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release.Name).To(Equal("other"))
+
+		release, err = lookups.ReleaseForJob("health-check", releases)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release.Name).To(Equal("other"))
+	})
+
+	It("does not reuse lookups for different releases", func() {
+		lookups := adapter.NewReleaseLookups()
+		_, err := lookups.ReleaseForJob("health-check", releases)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = lookups.ReleaseForJob("health-check", releases[:1])
+		Expect(err).To(MatchError("no release provided for job health-check"))
+	})
+
+	It("looks jobs up every time when nil", func() {
+		var lookups *adapter.ReleaseLookups
+		release, err := lookups.ReleaseForJob("redis-server", releases)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release.Name).To(Equal("redis"))
+	})
+})
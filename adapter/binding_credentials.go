@@ -0,0 +1,89 @@
+package adapter
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DefaultCredentialsProfile = "default"
+	SpringCredentialsProfile  = "spring"
+	URLCredentialsProfile     = "url"
+
+	CredentialsProfileParameter = "credentials_profile"
+)
+
+type redisConnection struct {
+	Host     string
+	Hosts    []string
+	Port     int
+	TLSPort  int
+	Username string
+	Password string
+	Modules  []string
+}
+
+// URI returns a redis:// URI for the connection, or a rediss:// one pointing
+// at the TLS port when the instance has TLS enabled.
+func (c redisConnection) URI() string {
+	scheme, port := "redis", c.Port
+	if c.TLSPort != 0 {
+		scheme, port = "rediss", c.TLSPort
+	}
+	uri := url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(c.Username, c.Password),
+		Host:   net.JoinHostPort(c.Host, strconv.Itoa(port)),
+	}
+	return uri.String()
+}
+
+// credentialsForProfile lays out the connection details the way the given
+// profile expects. The default profile keeps every key the adapter has always
+// returned; the other profiles only return what their client libraries read.
+func credentialsForProfile(profile string, connection redisConnection, defaultCredentials map[string]interface{}) (map[string]interface{}, error) {
+	var credentials map[string]interface{}
+
+	switch profile {
+	case "", DefaultCredentialsProfile:
+		credentials = defaultCredentials
+		credentials["hostname"] = connection.Host
+		credentials["uri"] = connection.URI()
+	case SpringCredentialsProfile:
+		// java-cfenv reads either uri or host, port and password
+		credentials = map[string]interface{}{
+			"host":     connection.Host,
+			"port":     connection.Port,
+			"password": connection.Password,
+			"uri":      connection.URI(),
+		}
+	case URLCredentialsProfile:
+		// node-redis and redis-py both connect from a single url
+		credentials = map[string]interface{}{
+			"url":      connection.URI(),
+			"uri":      connection.URI(),
+			"host":     connection.Host,
+			"port":     connection.Port,
+			"password": connection.Password,
+		}
+	default:
+		return nil, fmt.Errorf(
+			"unknown credentials profile %q, supported profiles are: %s, %s, %s",
+			profile, DefaultCredentialsProfile, SpringCredentialsProfile, URLCredentialsProfile,
+		)
+	}
+
+	if connection.TLSPort != 0 {
+		credentials["tls_port"] = connection.TLSPort
+	}
+	if connection.Username != "" {
+		credentials["username"] = connection.Username
+	}
+	if len(connection.Modules) > 0 {
+		// lets clients check for modules without running MODULE LIST
+		credentials["modules"] = connection.Modules
+	}
+	return credentials, nil
+}
@@ -0,0 +1,110 @@
+package adapter_test
+
+import (
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Binding credentials", func() {
+	var (
+		binder          adapter.Binder
+		redisProperties map[interface{}]interface{}
+		requestParams   serviceadapter.RequestParameters
+		binding         serviceadapter.Binding
+		bindingErr      error
+	)
+
+	BeforeEach(func() {
+		binder = adapter.Binder{StderrLogger: log.New(GinkgoWriter, "", log.LstdFlags)}
+		redisProperties = map[interface{}]interface{}{"password": "p@ss/word"}
+		requestParams = serviceadapter.RequestParameters{}
+	})
+
+	JustBeforeEach(func() {
+		binding, bindingErr = binder.CreateBinding(serviceadapter.CreateBindingParams{
+			BindingID:          "some-binding-id",
+			DeploymentTopology: bosh.BoshVMs{"redis-server": []string{"10.0.0.1"}},
+			Manifest: bosh.BoshManifest{
+				InstanceGroups: []bosh.InstanceGroup{{
+					Jobs: []bosh.Job{{Properties: map[string]interface{}{"redis": redisProperties}}},
+				}},
+			},
+			RequestParams: requestParams,
+		})
+	})
+
+	It("returns a redis uri and hostname", func() {
+		Expect(bindingErr).NotTo(HaveOccurred())
+		Expect(binding.Credentials["uri"]).To(Equal("redis://:p%40ss%2Fword@10.0.0.1:6379"))
+		Expect(binding.Credentials["hostname"]).To(Equal("10.0.0.1"))
+		Expect(binding.Credentials).NotTo(HaveKey("tls_port"))
+	})
+
+	Context("when the instance has TLS enabled", func() {
+		BeforeEach(func() {
+			redisProperties["tls_port"] = 16379
+		})
+
+		It("returns a rediss uri and the tls port", func() {
+			Expect(bindingErr).NotTo(HaveOccurred())
+			Expect(binding.Credentials["uri"]).To(Equal("rediss://:p%40ss%2Fword@10.0.0.1:16379"))
+			Expect(binding.Credentials["tls_port"]).To(Equal(16379))
+		})
+	})
+
+	Context("when the spring profile is configured", func() {
+		BeforeEach(func() {
+			binder.Config.BindingCredentialsProfile = adapter.SpringCredentialsProfile
+		})
+
+		It("returns the java-cfenv layout", func() {
+			Expect(bindingErr).NotTo(HaveOccurred())
+			Expect(binding.Credentials).To(Equal(map[string]interface{}{
+				"host":     "10.0.0.1",
+				"port":     adapter.RedisServerPort,
+				"password": "p@ss/word",
+				"uri":      "redis://:p%40ss%2Fword@10.0.0.1:6379",
+			}))
+		})
+
+		Context("and the bind request asks for the url profile", func() {
+			BeforeEach(func() {
+				requestParams = serviceadapter.RequestParameters{
+					"parameters": map[string]interface{}{"credentials_profile": "url"},
+				}
+			})
+
+			It("uses the requested profile", func() {
+				Expect(bindingErr).NotTo(HaveOccurred())
+				Expect(binding.Credentials["url"]).To(Equal("redis://:p%40ss%2Fword@10.0.0.1:6379"))
+			})
+		})
+	})
+
+	Context("when an unknown profile is requested", func() {
+		BeforeEach(func() {
+			requestParams = serviceadapter.RequestParameters{
+				"parameters": map[string]interface{}{"credentials_profile": "ruby"},
+			}
+		})
+
+		It("fails with the supported profiles", func() {
+			Expect(bindingErr).To(matchAdapterError(adapter.UserErrorKind, `unknown credentials profile "ruby", supported profiles are: default, spring, url`))
+		})
+	})
+
+	Context("when an unknown profile is configured", func() {
+		BeforeEach(func() {
+			binder.Config.BindingCredentialsProfile = "ruby"
+		})
+
+		It("fails with an error for operators", func() {
+			Expect(bindingErr).To(matchAdapterError(adapter.OperatorErrorKind, `unknown credentials profile "ruby", supported profiles are: default, spring, url`))
+		})
+	})
+})
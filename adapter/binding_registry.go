@@ -0,0 +1,50 @@
+package adapter
+
+// BindingUsernamePrefix is prepended to the binding ID to name the Redis ACL
+// user that holds a binding's credentials.
+const BindingUsernamePrefix = "binding-"
+
+// RedisInstance holds what is needed to connect to a service instance as the
+// admin user.
+type RedisInstance struct {
+	Hosts         []string
+	Port          int
+	TLSPort       int
+	AdminPassword string
+	CACert        string
+
+	// Nodes has one address of each Redis VM. Registries that keep bindings
+	// on the Redis servers keep them on every node, since clients may
+	// connect to any of them. When empty, the first reachable host is used.
+	Nodes []string
+
+	// DeniedCommands are the commands binding users may not run.
+	DeniedCommands []string
+}
+
+// BindingRegistry tracks the bindings that exist on a service instance, so that
+// duplicate binds and unbinds of unknown bindings can be reported to the broker.
+type BindingRegistry interface {
+	BindingExists(instance RedisInstance, bindingID string) (bool, error)
+	CreateBinding(instance RedisInstance, bindingID, password string) error
+	DeleteBinding(instance RedisInstance, bindingID string) error
+}
+
+func BindingUsername(bindingID string) string {
+	return BindingUsernamePrefix + bindingID
+}
+
+// nodeInstances splits the instance into one instance per node.
+func (i RedisInstance) nodeInstances() []RedisInstance {
+	if len(i.Nodes) == 0 {
+		return []RedisInstance{i}
+	}
+	var nodes []RedisInstance
+	for _, node := range i.Nodes {
+		nodeInstance := i
+		nodeInstance.Hosts = []string{node}
+		nodeInstance.Nodes = nil
+		nodes = append(nodes, nodeInstance)
+	}
+	return nodes
+}
@@ -0,0 +1,121 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp"
+)
+
+const (
+	DefaultBindingVerificationTimeout = 5 * time.Second
+
+	bindingProbeKeyPrefix = "redis-service-adapter:binding-check:"
+)
+
+// verifyBinding connects to the instance with the credentials being handed to
+// the app, so a broken instance or wrong credentials fail the bind instead of
+// the app's startup.
+func (b Binder) verifyBinding(instance RedisInstance, connection redisConnection) error {
+	client, err := connectToInstance(instance, connection.Username, connection.Password, b.verificationTimeout(), 1)
+	if err != nil {
+		return fmt.Errorf("could not verify the binding, the service instance is unreachable or rejected the credentials: %w", err)
+	}
+	defer client.Close()
+
+	pong, err := client.Do("PING")
+	if err != nil {
+		return fmt.Errorf("could not verify the binding, PING failed: %w", err)
+	}
+	if pong.Str != "PONG" {
+		return fmt.Errorf("could not verify the binding, unexpected PING reply %q", pong.String())
+	}
+
+	if connection.Username != "" {
+		user, err := client.Do("ACL", "WHOAMI")
+		if err != nil {
+			return fmt.Errorf("could not verify the binding, ACL WHOAMI failed: %w", err)
+		}
+		if user.Str != connection.Username {
+			return fmt.Errorf("could not verify the binding, connected as %q instead of %q", user.Str, connection.Username)
+		}
+		return b.verifyDataAccess(instance, connection.Username)
+	}
+	return nil
+}
+
+// verifyAdminLogin logs in to the instance with the admin password from its
+// manifest.
+func (b Binder) verifyAdminLogin(instance RedisInstance) error {
+	client, err := connectToInstance(instance, "", instance.AdminPassword, b.verificationTimeout(), 1)
+	if err != nil {
+		return fmt.Errorf("could not log in to the service instance: %w", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Do("PING"); err != nil {
+		return fmt.Errorf("could not log in to the service instance, PING failed: %w", err)
+	}
+	return nil
+}
+
+func (b Binder) verificationTimeout() time.Duration {
+	timeout := time.Duration(b.Config.BindingVerificationTimeoutSeconds) * time.Second
+	if timeout == 0 {
+		return DefaultBindingVerificationTimeout
+	}
+	return timeout
+}
+
+// classifyVerificationError blames the network for connections that could not
+// be made or timed out, which retrying may fix, and operators for anything
+// else, e.g. credentials the instance rejects or a binding user lacking
+// permissions, which retrying will not.
+func classifyVerificationError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return NewTransientError(err)
+	}
+	return NewOperatorError(err)
+}
+
+// verifyDataAccess asks the instance, as the admin user, whether the binding
+// user may write, read and delete keys, since scoped binding users can
+// authenticate without being allowed to touch any data. ACL DRYRUN runs
+// nothing, so the check works on replicas and full instances and leaves no
+// keys behind. Instances older than Redis 7 do not have it, and are only
+// checked by logging in.
+func (b Binder) verifyDataAccess(instance RedisInstance, username string) error {
+	client, err := connectToInstance(instance, "", instance.AdminPassword, b.verificationTimeout(), 1)
+	if err != nil {
+		return fmt.Errorf("could not verify the binding, the service instance rejected the admin credentials: %w", err)
+	}
+	defer client.Close()
+
+	key := bindingProbeKeyPrefix + username
+	checks := []struct {
+		access  string
+		command []string
+	}{
+		{"write", []string{"SET", key, "probe"}},
+		{"read", []string{"GET", key}},
+		{"delete", []string{"DEL", key}},
+	}
+	for _, check := range checks {
+		reply, err := client.Do(append([]string{"ACL", "DRYRUN", username}, check.command...)...)
+		var serverErr resp.ServerError
+		if errors.As(err, &serverErr) && strings.Contains(strings.ToLower(serverErr.Message), "unknown subcommand") {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not verify the binding, ACL DRYRUN failed: %w", err)
+		}
+		if reply.Str != "OK" {
+			return fmt.Errorf("could not verify the binding, the binding user cannot %s keys: %s", check.access, reply.Str)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp/resptest"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Binding verification", func() {
+	var (
+		server          *resptest.Server
+		binder          adapter.Binder
+		stderr          *gbytes.Buffer
+		redisProperties map[interface{}]interface{}
+		bindingErr      error
+	)
+
+	BeforeEach(func() {
+		server = resptest.NewTLSServer("admin-password")
+		stderr = gbytes.NewBuffer()
+		binder = adapter.Binder{
+			StderrLogger: log.New(io.MultiWriter(stderr, GinkgoWriter), "", log.LstdFlags),
+			Config: adapter.Config{
+				VerifyBindings:                    true,
+				BindingVerificationTimeoutSeconds: 1,
+			},
+		}
+		redisProperties = map[interface{}]interface{}{
+			"password":                 "admin-password",
+			"tls_port":                 server.Port(),
+			adapter.GeneratedSecretKey: path(adapter.GeneratedSecretKey),
+			adapter.ManagedSecretKey:   path(adapter.ManagedSecretKey),
+			"ca_cert":                  "((instance_certificate.ca))",
+			"private_key":              "((instance_certificate.private_key))",
+			"certificate":              "((instance_certificate.certificate))",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		secrets := defaultMap()
+		secrets["((instance_certificate.ca))"] = server.CACert
+		_, bindingErr = binder.CreateBinding(serviceadapter.CreateBindingParams{
+			BindingID:          "some-binding",
+			DeploymentTopology: bosh.BoshVMs{"redis-server": []string{server.Host()}},
+			Manifest: bosh.BoshManifest{
+				Name: "service-instance_some-instance",
+				InstanceGroups: []bosh.InstanceGroup{{
+					Jobs: []bosh.Job{{Properties: map[string]interface{}{"redis": redisProperties}}},
+				}},
+			},
+			Secrets: secrets,
+		})
+	})
+
+	It("connects with the binding credentials and pings the instance", func() {
+		Expect(bindingErr).NotTo(HaveOccurred())
+		Expect(server.Commands()).To(Equal([]string{"AUTH", "PING"}))
+	})
+
+	Context("when the credentials are wrong", func() {
+		BeforeEach(func() {
+			redisProperties["password"] = "wrong-password"
+		})
+
+		It("fails the bind with a clear message", func() {
+			Expect(bindingErr).To(matchAdapterError(adapter.OperatorErrorKind, ContainSubstring("could not verify the binding, the service instance is unreachable or rejected the credentials")))
+			Expect(adapter.ErrorDetail(bindingErr)).To(ContainSubstring("WRONGPASS"))
+			Expect(stderr).To(gbytes.Say("could not verify the binding"))
+		})
+	})
+
+	Context("when the instance is unreachable", func() {
+		BeforeEach(func() {
+			server.Close()
+		})
+
+		It("fails the bind with an error worth retrying", func() {
+			Expect(bindingErr).To(matchAdapterError(adapter.TransientErrorKind, ContainSubstring("could not connect to")))
+		})
+	})
+
+	Context("with scoped binding users", func() {
+		BeforeEach(func() {
+			binder.Bindings = adapter.ACLBindingRegistry{}
+		})
+
+		It("checks the binding user can write, read and delete keys without touching any", func() {
+			Expect(bindingErr).NotTo(HaveOccurred())
+			Expect(server.DryRuns()).To(Equal([]string{"SET", "GET", "DEL"}))
+			Expect(server.Commands()).NotTo(ContainElement("SET"))
+			Expect(server.Commands()).NotTo(ContainElement("GET"))
+			Expect(server.Commands()).NotTo(ContainElement("DEL"))
+			Expect(server.Users()).To(Equal([]string{"binding-some-binding"}))
+		})
+
+		Context("when the binding user cannot write keys", func() {
+			BeforeEach(func() {
+				redisProperties["denied_commands"] = []interface{}{"SET"}
+			})
+
+			It("fails the bind and removes the binding user", func() {
+				Expect(bindingErr).To(matchAdapterError(adapter.OperatorErrorKind, ContainSubstring("the binding user cannot write keys")))
+				Expect(adapter.ErrorDetail(bindingErr)).To(ContainSubstring("no permissions to run the 'set' command"))
+				Expect(server.Users()).To(BeEmpty())
+			})
+		})
+
+		Context("when the binding user is not usable", func() {
+			var registry *fakeBindingRegistry
+
+			BeforeEach(func() {
+				registry = &fakeBindingRegistry{bindings: map[string]string{}}
+				binder.Bindings = registry
+			})
+
+			It("fails the bind and removes the binding", func() {
+				Expect(bindingErr).To(matchAdapterError(adapter.OperatorErrorKind, ContainSubstring("WRONGPASS")))
+				Expect(registry.bindings).To(BeEmpty())
+			})
+		})
+	})
+})
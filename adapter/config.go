@@ -1,20 +1,67 @@
 package adapter
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
+	"strings"
 
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// CurrentConfigVersion is the newest config schema this adapter understands.
+// Configs without a config_version are treated as version 1.
+const CurrentConfigVersion = 1
+
+const (
+	// ConfigPathEnvVar overrides where the config file is read from.
+	ConfigPathEnvVar = "REDIS_ADAPTER_CONFIG_PATH"
+
+	// ConfigOverrideEnvPrefix prefixes environment variables overriding single
+	// config keys, e.g. REDIS_ADAPTER_OVERRIDE_SECURE_MANIFESTS_ENABLED=true.
+	// Nested keys are separated by a double underscore, as in
+	// REDIS_ADAPTER_OVERRIDE_CREDHUB__CLIENT_ID.
+	ConfigOverrideEnvPrefix = "REDIS_ADAPTER_OVERRIDE_"
+
+	redactedValue = "<redacted>"
+)
+
+var secretConfigKeys = []string{"client_secret", "password", "secret", "token"}
+
 type Config struct {
-	RedisInstanceGroupName         string `yaml:"redis_instance_group_name"`
-	IgnoreODBManagedSecretOnUpdate bool   `yaml:"ignore_odb_managed_secret_on_update"`
-	SecureManifestsEnabled         bool   `yaml:"secure_manifests_enabled"`
+	ConfigVersion                     int                `yaml:"config_version"`
+	RedisInstanceGroupName            string             `yaml:"redis_instance_group_name"`
+	IgnoreODBManagedSecretOnUpdate    bool               `yaml:"ignore_odb_managed_secret_on_update"`
+	SecureManifestsEnabled            bool               `yaml:"secure_manifests_enabled"`
+	BindingHostPolicy                 string             `yaml:"binding_host_policy"`
+	BindingCredentialsProfile         string             `yaml:"binding_credentials_profile"`
+	BindingCredentialsMode            string             `yaml:"binding_credentials_mode"`
+	DebugBindingCredentials           bool               `yaml:"debug_binding_credentials"`
+	CredHub                           CredHubConfig      `yaml:"credhub"`
+	ACLBindingsEnabled                bool               `yaml:"acl_bindings_enabled"`
+	VerifyBindings                    bool               `yaml:"verify_bindings"`
+	BindingVerificationTimeoutSeconds int                `yaml:"binding_verification_timeout_seconds"`
+	DashboardServerURL                string             `yaml:"dashboard_server_url"`
+	DashboardUAAURL                   string             `yaml:"dashboard_uaa_url"`
+	LogFormat                         string             `yaml:"log_format"`
+	LogLevel                          string             `yaml:"log_level"`
+	AuditLogPath                      string             `yaml:"audit_log_path"`
+	AuditLogMaxSizeMB                 int                `yaml:"audit_log_max_size_mb"`
+	AuditLogMaxFiles                  int                `yaml:"audit_log_max_files"`
+	RecordingDir                      string             `yaml:"recording_dir"`
+	ServiceDefinition                 *ServiceDefinition `yaml:"service_definition,omitempty"`
+	ManifestOps                       []ManifestOp       `yaml:"manifest_ops,omitempty"`
 }
 
 func LoadConfig(path string, logger *log.Logger) (Config, error) {
+	return LoadConfigWithEnv(path, nil, logger)
+}
+
+// LoadConfigWithEnv loads the config file and then layers the overrides found
+// in environ, a list of KEY=value pairs as returned by os.Environ, on top.
+func LoadConfigWithEnv(path string, environ []string, logger *log.Logger) (Config, error) {
 	config := Config{}
 
 	ymlFile, err := ioutil.ReadFile(path)
@@ -24,11 +71,243 @@ func LoadConfig(path string, logger *log.Logger) (Config, error) {
 		return Config{}, wrappedErr
 	}
 
-	err = yaml.Unmarshal(ymlFile, &config)
+	ymlFile, err = applyEnvOverrides(ymlFile, environ)
+	if err != nil {
+		wrappedErr := errors.Wrap(err, "Error, could not apply config overrides from the environment")
+		logger.Println(wrappedErr.Error())
+		return Config{}, wrappedErr
+	}
+
+	err = yaml.UnmarshalStrict(ymlFile, &config)
 	if err != nil {
 		wrappedErr := errors.Wrap(err, "Error, could not parse config YAML")
 		logger.Println(wrappedErr.Error())
 		return Config{}, wrappedErr
 	}
+
+	config.ApplyDefaults()
+
+	if err := config.Validate(); err != nil {
+		wrappedErr := errors.Wrap(err, "Error, invalid config")
+		logger.Println(wrappedErr.Error())
+		return Config{}, wrappedErr
+	}
 	return config, nil
 }
+
+// ConfigPath returns the config path set in environ, or defaultPath.
+func ConfigPath(environ []string, defaultPath string) string {
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, ConfigPathEnvVar+"=") {
+			return strings.TrimPrefix(kv, ConfigPathEnvVar+"=")
+		}
+	}
+	return defaultPath
+}
+
+func applyEnvOverrides(ymlFile []byte, environ []string) ([]byte, error) {
+	var overrides []string
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, ConfigOverrideEnvPrefix) {
+			overrides = append(overrides, strings.TrimPrefix(kv, ConfigOverrideEnvPrefix))
+		}
+	}
+	if len(overrides) == 0 {
+		return ymlFile, nil
+	}
+
+	document := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(ymlFile, &document); err != nil {
+		return nil, err
+	}
+
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("malformed override %s%s", ConfigOverrideEnvPrefix, override)
+		}
+
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(parts[1]), &value); err != nil {
+			return nil, fmt.Errorf("could not parse value of %s%s: %s", ConfigOverrideEnvPrefix, parts[0], err)
+		}
+
+		keys := strings.Split(strings.ToLower(parts[0]), "__")
+		current := document
+		for _, key := range keys[:len(keys)-1] {
+			nested, ok := current[key].(map[interface{}]interface{})
+			if !ok {
+				nested = map[interface{}]interface{}{}
+				current[key] = nested
+			}
+			current = nested
+		}
+		current[keys[len(keys)-1]] = value
+	}
+
+	return yaml.Marshal(document)
+}
+
+// RedactedYAML renders the effective config with secret values hidden.
+func (c Config) RedactedYAML() ([]byte, error) {
+	configBytes, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	document := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(configBytes, &document); err != nil {
+		return nil, err
+	}
+	redactSecretKeys(document)
+	return yaml.Marshal(document)
+}
+
+func redactSecretKeys(document map[interface{}]interface{}) {
+	for key, value := range document {
+		if redactNestedSecretKeys(value) {
+			continue
+		}
+		for _, secretKey := range secretConfigKeys {
+			if strings.HasSuffix(fmt.Sprint(key), secretKey) && value != "" {
+				document[key] = redactedValue
+			}
+		}
+	}
+}
+
+// redactNestedSecretKeys redacts the maps value holds, directly or in lists,
+// and reports whether value was a map or a list.
+func redactNestedSecretKeys(value interface{}) bool {
+	switch nested := value.(type) {
+	case map[interface{}]interface{}:
+		redactSecretKeys(nested)
+	case []interface{}:
+		for _, item := range nested {
+			redactNestedSecretKeys(item)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *Config) ApplyDefaults() {
+	if c.ConfigVersion == 0 {
+		c.ConfigVersion = 1
+	}
+	if c.RedisInstanceGroupName == "" {
+		c.RedisInstanceGroupName = RedisJobName
+		if c.ServiceDefinition != nil && len(c.ServiceDefinition.InstanceGroups) > 0 {
+			c.RedisInstanceGroupName = c.ServiceDefinition.InstanceGroups[0].Name
+		}
+	}
+	if c.BindingHostPolicy == "" {
+		c.BindingHostPolicy = DNSFirstHostPolicy
+	}
+	if c.BindingCredentialsProfile == "" {
+		c.BindingCredentialsProfile = DefaultCredentialsProfile
+	}
+	if c.BindingCredentialsMode == "" {
+		c.BindingCredentialsMode = InlineCredentialsMode
+	}
+	if c.BindingVerificationTimeoutSeconds == 0 {
+		c.BindingVerificationTimeoutSeconds = int(DefaultBindingVerificationTimeout.Seconds())
+	}
+	if c.CredHub.PathPrefix == "" {
+		c.CredHub.PathPrefix = DefaultCredHubPathPrefix
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = JSONLogFormat
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = InfoLogLevel
+	}
+	if c.AuditLogMaxSizeMB == 0 {
+		c.AuditLogMaxSizeMB = DefaultAuditLogMaxSizeMB
+	}
+	if c.AuditLogMaxFiles == 0 {
+		c.AuditLogMaxFiles = DefaultAuditLogMaxFiles
+	}
+}
+
+// Validate reports every problem with the config at once, so operators can fix
+// them in a single pass.
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.ConfigVersion < 1 || c.ConfigVersion > CurrentConfigVersion {
+		problems = append(problems, fmt.Sprintf("config_version %d is not supported, this adapter supports versions 1 to %d", c.ConfigVersion, CurrentConfigVersion))
+	}
+	if strings.TrimSpace(c.RedisInstanceGroupName) == "" {
+		problems = append(problems, "redis_instance_group_name must not be blank")
+	}
+	problems = append(problems, checkOneOf("binding_host_policy", c.BindingHostPolicy, DNSFirstHostPolicy, IPFirstHostPolicy)...)
+	problems = append(problems, checkOneOf("binding_credentials_profile", c.BindingCredentialsProfile, DefaultCredentialsProfile, SpringCredentialsProfile, URLCredentialsProfile)...)
+	problems = append(problems, checkOneOf("binding_credentials_mode", c.BindingCredentialsMode, InlineCredentialsMode, CredHubRefCredentialsMode)...)
+
+	if c.BindingCredentialsMode == CredHubRefCredentialsMode {
+		problems = append(problems, checkURL("credhub.url", c.CredHub.URL)...)
+		problems = append(problems, checkURL("credhub.uaa_url", c.CredHub.UAAURL)...)
+		if c.CredHub.ClientID == "" {
+			problems = append(problems, "credhub.client_id is required when binding_credentials_mode is credhub-ref")
+		}
+		if c.CredHub.ClientSecret == "" {
+			problems = append(problems, "credhub.client_secret is required when binding_credentials_mode is credhub-ref")
+		}
+	}
+	if !strings.HasPrefix(c.CredHub.PathPrefix, "/") {
+		problems = append(problems, fmt.Sprintf("credhub.path_prefix must start with /, got %q", c.CredHub.PathPrefix))
+	}
+
+	if c.BindingVerificationTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("binding_verification_timeout_seconds must be positive, got %d", c.BindingVerificationTimeoutSeconds))
+	}
+	if c.DashboardServerURL != "" {
+		problems = append(problems, checkURL("dashboard_server_url", c.DashboardServerURL)...)
+	}
+	if c.DashboardUAAURL != "" {
+		problems = append(problems, checkURL("dashboard_uaa_url", c.DashboardUAAURL)...)
+	}
+	problems = append(problems, checkOneOf("log_format", c.LogFormat, JSONLogFormat, TextLogFormat)...)
+	if c.AuditLogMaxSizeMB < 0 {
+		problems = append(problems, fmt.Sprintf("audit_log_max_size_mb must be positive, got %d", c.AuditLogMaxSizeMB))
+	}
+	if c.AuditLogMaxFiles < 0 {
+		problems = append(problems, fmt.Sprintf("audit_log_max_files must be positive, got %d", c.AuditLogMaxFiles))
+	}
+	problems = append(problems, checkOneOf("log_level", c.LogLevel, DebugLogLevel, InfoLogLevel, WarnLogLevel, ErrorLogLevel)...)
+
+	if err := ValidateManifestOps(c.ManifestOps); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if c.ServiceDefinition != nil {
+		if err := c.ServiceDefinition.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("service_definition: %s", err))
+		} else if c.ServiceDefinition.InstanceGroups[0].Name != c.RedisInstanceGroupName {
+			problems = append(problems, fmt.Sprintf("service_definition's first instance group must be redis_instance_group_name %q, got %q", c.RedisInstanceGroupName, c.ServiceDefinition.InstanceGroups[0].Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkOneOf(key, value string, allowed ...string) []string {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)}
+}
+
+func checkURL(key, value string) []string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return []string{fmt.Sprintf("%s must be an absolute URL, got %q", key, value)}
+	}
+	return nil
+}
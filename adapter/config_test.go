@@ -41,10 +41,175 @@ var _ = Describe("Config", func() {
 		Expect(stderr).To(gbytes.Say("Error, could not read config file"))
 	})
 
+	It("applies defaults for missing keys", func() {
+		configFilePath := getFixturePath("binding-config-manifest-secrets-enabled.yml")
+		config, err := adapter.LoadConfig(configFilePath, stderrLogger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.ConfigVersion).To(Equal(1))
+		Expect(config.RedisInstanceGroupName).To(Equal("redis-server"))
+		Expect(config.BindingHostPolicy).To(Equal(adapter.DNSFirstHostPolicy))
+		Expect(config.BindingCredentialsProfile).To(Equal(adapter.DefaultCredentialsProfile))
+		Expect(config.BindingCredentialsMode).To(Equal(adapter.InlineCredentialsMode))
+		Expect(config.BindingVerificationTimeoutSeconds).To(Equal(5))
+		Expect(config.CredHub.PathPrefix).To(Equal(adapter.DefaultCredHubPathPrefix))
+		Expect(config.LogFormat).To(Equal(adapter.JSONLogFormat))
+		Expect(config.LogLevel).To(Equal(adapter.InfoLogLevel))
+	})
+
+	It("loads every option", func() {
+		configFilePath := getFixturePath("config-full.yml")
+		config, err := adapter.LoadConfig(configFilePath, stderrLogger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.RedisInstanceGroupName).To(Equal("redis"))
+		Expect(config.BindingCredentialsMode).To(Equal(adapter.CredHubRefCredentialsMode))
+		Expect(config.CredHub.ClientID).To(Equal("redis-adapter"))
+		Expect(config.BindingVerificationTimeoutSeconds).To(Equal(10))
+		Expect(config.ManifestOps).To(Equal([]adapter.ManifestOp{{
+			Type:  adapter.ReplaceManifestOp,
+			Path:  "/instance_groups/name=redis/env?/bosh/swap_size",
+			Value: 0,
+			When:  map[string]interface{}{"persistence": true},
+		}}))
+	})
+
+	It("errors when the config file has unknown keys", func() {
+		configFilePath := getFixturePath("config-unknown-key.yml")
+		_, err := adapter.LoadConfig(configFilePath, stderrLogger)
+		Expect(err).To(MatchError(ContainSubstring("Error, could not parse config YAML")))
+		Expect(err).To(MatchError(ContainSubstring("field secure_manifest_enabled not found")))
+	})
+
+	It("errors when the config version is not supported", func() {
+		configFilePath := getFixturePath("config-unsupported-version.yml")
+		_, err := adapter.LoadConfig(configFilePath, stderrLogger)
+		Expect(err).To(MatchError("Error, invalid config: config_version 2 is not supported, this adapter supports versions 1 to 1"))
+		Expect(stderr).To(gbytes.Say("config_version 2 is not supported"))
+	})
+
+	It("reports every invalid value", func() {
+		configFilePath := getFixturePath("config-invalid-values.yml")
+		_, err := adapter.LoadConfig(configFilePath, stderrLogger)
+		Expect(err).To(MatchError(ContainSubstring(`binding_host_policy must be one of dns-first, ip-first, got "closest"`)))
+		Expect(err).To(MatchError(ContainSubstring(`credhub.url must be an absolute URL, got "credhub.service.internal"`)))
+		Expect(err).To(MatchError(ContainSubstring(`credhub.uaa_url must be an absolute URL, got ""`)))
+		Expect(err).To(MatchError(ContainSubstring("credhub.client_id is required when binding_credentials_mode is credhub-ref")))
+	})
+
+	It("loads a service definition", func() {
+		config, err := adapter.LoadConfig(getFixturePath("config-service-definition.yml"), stderrLogger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.RedisInstanceGroupName).To(Equal("redis"))
+		Expect(config.ServiceDefinitionOrDefault().InstanceGroups).To(HaveLen(2))
+		Expect(config.ServiceDefinitionOrDefault().InstanceGroups[1].Lifecycle).To(Equal(adapter.LifecycleErrandType))
+	})
+
+	It("defaults to the Redis service definition", func() {
+		config, err := adapter.LoadConfig(getFixturePath("config-full.yml"), stderrLogger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.ServiceDefinition).To(BeNil())
+		Expect(config.ServiceDefinitionOrDefault()).To(Equal(adapter.DefaultServiceDefinition("redis")))
+	})
+
+	It("reports every problem with the service definition", func() {
+		_, err := adapter.LoadConfig(getFixturePath("config-service-definition-invalid.yml"), stderrLogger)
+		Expect(err).To(MatchError(ContainSubstring("service_definition: job redis-server property ${adapter.port} is not an adapter value")))
+		Expect(err).To(MatchError(ContainSubstring("job redis-server property ${parameters.maxclients} refers to a parameter missing from parameters")))
+		Expect(err).To(MatchError(ContainSubstring("job redis-server property ${team} must look like ${namespace.key}")))
+		Expect(err).To(MatchError(ContainSubstring("instance group redis is defined more than once")))
+		Expect(err).To(MatchError(ContainSubstring(`instance group redis lifecycle must be one of service, errand, got "sometimes"`)))
+		Expect(err).To(MatchError(ContainSubstring("instance group redis has no jobs")))
+	})
+
+	It("errors when the service definition does not start with the Redis server instance group", func() {
+		_, err := adapter.LoadConfigWithEnv(getFixturePath("config-service-definition.yml"), []string{
+			"REDIS_ADAPTER_OVERRIDE_REDIS_INSTANCE_GROUP_NAME=redis-server",
+		}, stderrLogger)
+		Expect(err).To(MatchError(ContainSubstring(`service_definition's first instance group must be redis_instance_group_name "redis-server", got "redis"`)))
+	})
+
 	It("errors when the config file is invalid", func() {
 		configFilePath := getFixturePath("binding-config-invalid.yml")
 		_, err := adapter.LoadConfig(configFilePath, stderrLogger)
 		Expect(err).To(MatchError(ContainSubstring("Error, could not parse config YAML")))
 		Expect(stderr).To(gbytes.Say("Error, could not parse config YAML"))
 	})
+
+	Describe("environment overrides", func() {
+		It("overrides keys from the config file", func() {
+			configFilePath := getFixturePath("config-full.yml")
+			config, err := adapter.LoadConfigWithEnv(configFilePath, []string{
+				"HOME=/home/vcap",
+				"REDIS_ADAPTER_OVERRIDE_SECURE_MANIFESTS_ENABLED=false",
+				"REDIS_ADAPTER_OVERRIDE_BINDING_VERIFICATION_TIMEOUT_SECONDS=30",
+				"REDIS_ADAPTER_OVERRIDE_CREDHUB__CLIENT_SECRET=env-secret",
+			}, stderrLogger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.SecureManifestsEnabled).To(BeFalse())
+			Expect(config.BindingVerificationTimeoutSeconds).To(Equal(30))
+			Expect(config.CredHub.ClientSecret).To(Equal("env-secret"))
+			Expect(config.CredHub.ClientID).To(Equal("redis-adapter"))
+		})
+
+		It("sets keys missing from the config file", func() {
+			configFilePath := getFixturePath("binding-config-manifest-secrets-enabled.yml")
+			config, err := adapter.LoadConfigWithEnv(configFilePath, []string{
+				"REDIS_ADAPTER_OVERRIDE_CREDHUB__PATH_PREFIX=/c/env",
+			}, stderrLogger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.CredHub.PathPrefix).To(Equal("/c/env"))
+		})
+
+		It("validates overridden values", func() {
+			configFilePath := getFixturePath("config-full.yml")
+			_, err := adapter.LoadConfigWithEnv(configFilePath, []string{
+				"REDIS_ADAPTER_OVERRIDE_BINDING_HOST_POLICY=closest",
+			}, stderrLogger)
+			Expect(err).To(MatchError(ContainSubstring(`binding_host_policy must be one of dns-first, ip-first, got "closest"`)))
+		})
+
+		It("errors on overrides of unknown keys", func() {
+			configFilePath := getFixturePath("config-full.yml")
+			_, err := adapter.LoadConfigWithEnv(configFilePath, []string{
+				"REDIS_ADAPTER_OVERRIDE_SECURE_MANIFEST_ENABLED=true",
+			}, stderrLogger)
+			Expect(err).To(MatchError(ContainSubstring("field secure_manifest_enabled not found")))
+		})
+	})
+
+	Describe("ConfigPath", func() {
+		It("uses the path from the environment", func() {
+			Expect(adapter.ConfigPath([]string{"REDIS_ADAPTER_CONFIG_PATH=/tmp/adapter.yml"}, "/default.yml")).To(Equal("/tmp/adapter.yml"))
+		})
+
+		It("falls back to the default path", func() {
+			Expect(adapter.ConfigPath([]string{"HOME=/home/vcap"}, "/default.yml")).To(Equal("/default.yml"))
+		})
+	})
+
+	Describe("RedactedYAML", func() {
+		It("hides secrets", func() {
+			config, err := adapter.LoadConfig(getFixturePath("config-full.yml"), stderrLogger)
+			Expect(err).NotTo(HaveOccurred())
+
+			configYAML, err := config.RedactedYAML()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(configYAML)).To(ContainSubstring("client_secret: <redacted>"))
+			Expect(string(configYAML)).To(ContainSubstring("client_id: redis-adapter"))
+			Expect(string(configYAML)).NotTo(ContainSubstring("some-secret"))
+		})
+
+		It("hides secrets nested in lists", func() {
+			config := adapter.Config{ManifestOps: []adapter.ManifestOp{{
+				Type:  "replace",
+				Path:  "/instance_groups/name=redis-server/jobs/name=redis-server/properties/redis/sentinel?",
+				Value: map[string]interface{}{"port": 26379, "password": "some-op-secret"},
+			}}}
+
+			configYAML, err := config.RedactedYAML()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(configYAML)).To(ContainSubstring("password: <redacted>"))
+			Expect(string(configYAML)).To(ContainSubstring("port: 26379"))
+			Expect(string(configYAML)).NotTo(ContainSubstring("some-op-secret"))
+		})
+	})
 })
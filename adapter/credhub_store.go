@@ -0,0 +1,167 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type CredentialStore interface {
+	SetCredentials(path string, credentials map[string]interface{}) error
+	// GrantReadAccess lets the app, identified by its GUID, read the
+	// credentials at path.
+	GrantReadAccess(path, appGUID string) error
+	DeleteCredentials(path string) error
+}
+
+type CredHubConfig struct {
+	URL          string `yaml:"url"`
+	UAAURL       string `yaml:"uaa_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	CACert       string `yaml:"ca_cert"`
+	PathPrefix   string `yaml:"path_prefix"`
+}
+
+// CredHubStore writes binding credentials to CredHub's data API,
+// authenticating with a UAA client credentials grant.
+type CredHubStore struct {
+	Config     CredHubConfig
+	HTTPClient *http.Client
+}
+
+func (s CredHubStore) SetCredentials(path string, credentials map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"name":  path,
+		"type":  "json",
+		"value": credentials,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPut, "/api/v1/data", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "could not store credentials at %s", path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not store credentials at %s: CredHub responded with status %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+// GrantReadAccess adds a permission for the app's instance identity, which is
+// how apps authenticate to CredHub to resolve credhub-ref credentials.
+func (s CredHubStore) GrantReadAccess(path, appGUID string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"path":       path,
+		"actor":      "mtls-app:" + appGUID,
+		"operations": []string{"read"},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPost, "/api/v2/permissions", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "could not grant app %s access to %s", appGUID, path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not grant app %s access to %s: CredHub responded with status %d", appGUID, path, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s CredHubStore) DeleteCredentials(path string) error {
+	resp, err := s.do(http.MethodDelete, "/api/v1/data?"+url.Values{"name": {path}}.Encode(), nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete credentials at %s", path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("could not delete credentials at %s: CredHub responded with status %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s CredHubStore) do(method, path string, body io.Reader) (*http.Response, error) {
+	httpClient, err := s.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.accessToken(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(s.Config.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	return httpClient.Do(req)
+}
+
+func (s CredHubStore) accessToken(httpClient *http.Client) (string, error) {
+	req, err := http.NewRequest(
+		http.MethodPost,
+		strings.TrimSuffix(s.Config.UAAURL, "/")+"/oauth/token",
+		strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()),
+	)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(s.Config.ClientID, s.Config.ClientSecret)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "could not reach UAA")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get a CredHub token: UAA responded with status %d", resp.StatusCode)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", errors.Wrap(err, "could not parse UAA token response")
+	}
+	return tokenResponse.AccessToken, nil
+}
+
+func (s CredHubStore) httpClient() (*http.Client, error) {
+	if s.HTTPClient != nil {
+		return s.HTTPClient, nil
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if s.Config.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(s.Config.CACert)) {
+			return nil, errors.New("could not parse CredHub CA certificate")
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return httpClient, nil
+}
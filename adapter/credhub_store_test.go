@@ -0,0 +1,133 @@
+package adapter_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+var _ = Describe("CredHubStore", func() {
+	var (
+		server          *httptest.Server
+		store           adapter.CredHubStore
+		credhubStatus   int
+		receivedMethod  string
+		receivedAuth    string
+		receivedName    string
+		receivedPayload map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		credhubStatus = http.StatusOK
+		receivedPayload = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth/token":
+				clientID, clientSecret, _ := r.BasicAuth()
+				Expect(r.ParseForm()).To(Succeed())
+				Expect(r.Form.Get("grant_type")).To(Equal("client_credentials"))
+				if clientID != "adapter-client" || clientSecret != "adapter-secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(`{"access_token":"some-token"}`))
+			case "/api/v1/data":
+				receivedMethod = r.Method
+				receivedAuth = r.Header.Get("Authorization")
+				receivedName = r.URL.Query().Get("name")
+				if r.Method == http.MethodPut {
+					Expect(json.NewDecoder(r.Body).Decode(&receivedPayload)).To(Succeed())
+				}
+				w.WriteHeader(credhubStatus)
+			case "/api/v2/permissions":
+				receivedMethod = r.Method
+				Expect(json.NewDecoder(r.Body).Decode(&receivedPayload)).To(Succeed())
+				w.WriteHeader(credhubStatus)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		store = adapter.CredHubStore{Config: adapter.CredHubConfig{
+			URL:          server.URL,
+			UAAURL:       server.URL,
+			ClientID:     "adapter-client",
+			ClientSecret: "adapter-secret",
+		}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("stores credentials as a json credential", func() {
+		err := store.SetCredentials("/c/redis/binding-id/credentials", map[string]interface{}{"password": "secret"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(receivedMethod).To(Equal(http.MethodPut))
+		Expect(receivedAuth).To(Equal("Bearer some-token"))
+		Expect(receivedPayload).To(Equal(map[string]interface{}{
+			"name":  "/c/redis/binding-id/credentials",
+			"type":  "json",
+			"value": map[string]interface{}{"password": "secret"},
+		}))
+	})
+
+	It("grants apps read access to credentials", func() {
+		credhubStatus = http.StatusCreated
+
+		err := store.GrantReadAccess("/c/redis/binding-id/credentials", "some-app-guid")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(receivedMethod).To(Equal(http.MethodPost))
+		Expect(receivedPayload).To(Equal(map[string]interface{}{
+			"path":       "/c/redis/binding-id/credentials",
+			"actor":      "mtls-app:some-app-guid",
+			"operations": []interface{}{"read"},
+		}))
+	})
+
+	It("fails when CredHub does not grant access", func() {
+		credhubStatus = http.StatusForbidden
+
+		err := store.GrantReadAccess("/c/redis/binding-id/credentials", "some-app-guid")
+
+		Expect(err).To(MatchError("could not grant app some-app-guid access to /c/redis/binding-id/credentials: CredHub responded with status 403"))
+	})
+
+	It("deletes credentials", func() {
+		credhubStatus = http.StatusNoContent
+
+		err := store.DeleteCredentials("/c/redis/binding-id/credentials")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(receivedMethod).To(Equal(http.MethodDelete))
+		Expect(receivedName).To(Equal("/c/redis/binding-id/credentials"))
+	})
+
+	It("does not fail when deleting credentials that are already gone", func() {
+		credhubStatus = http.StatusNotFound
+
+		Expect(store.DeleteCredentials("/c/redis/binding-id/credentials")).To(Succeed())
+	})
+
+	It("fails when CredHub rejects the credentials", func() {
+		credhubStatus = http.StatusForbidden
+
+		err := store.SetCredentials("/c/redis/binding-id/credentials", map[string]interface{}{})
+
+		Expect(err).To(MatchError("could not store credentials at /c/redis/binding-id/credentials: CredHub responded with status 403"))
+	})
+
+	It("fails when UAA rejects the client", func() {
+		store.Config.ClientSecret = "wrong"
+
+		err := store.SetCredentials("/c/redis/binding-id/credentials", map[string]interface{}{})
+
+		Expect(err).To(MatchError(ContainSubstring("UAA responded with status 401")))
+	})
+})
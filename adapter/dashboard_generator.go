@@ -2,12 +2,22 @@ package adapter
 
 import (
 	"fmt"
+	"strings"
+
 	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 )
 
-type DashboardGenerator struct{}
+type DashboardGenerator struct {
+	Config Config
+}
 
 func (d DashboardGenerator) DashboardUrl(params serviceadapter.DashboardUrlParams) (serviceadapter.DashboardUrl, error) {
+	if d.Config.DashboardServerURL != "" {
+		return serviceadapter.DashboardUrl{
+			DashboardUrl: strings.TrimSuffix(d.Config.DashboardServerURL, "/") + dashboardInstancesPath + params.InstanceID,
+		}, nil
+	}
+
 	return serviceadapter.DashboardUrl{
 		DashboardUrl: fmt.Sprintf("https://example.com/dashboard/%s", params.InstanceID),
 	}, nil
@@ -21,4 +21,17 @@ var _ = Describe("DashboardGenerator", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(dashboard.DashboardUrl).To(Equal("https://example.com/dashboard/some-instance-id"))
 	})
+
+	It("returns the dashboard server url when one is configured", func() {
+		generator := adapter.DashboardGenerator{
+			Config: adapter.Config{DashboardServerURL: "https://redis-dashboard.example.com/"},
+		}
+
+		dashboard, err := generator.DashboardUrl(serviceadapter.DashboardUrlParams{
+			InstanceID: "some-instance-id",
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dashboard.DashboardUrl).To(Equal("https://redis-dashboard.example.com/instances/some-instance-id"))
+	})
 })
@@ -0,0 +1,342 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const dashboardInstancesPath = "/instances/"
+
+type ManifestSource interface {
+	Manifest(instanceID string) (bosh.BoshManifest, error)
+}
+
+// DirectoryManifestSource reads manifests named <instance-id>.yml from Dir.
+type DirectoryManifestSource struct {
+	Dir string
+}
+
+func (s DirectoryManifestSource) Manifest(instanceID string) (bosh.BoshManifest, error) {
+	if instanceID == "" || strings.ContainsAny(instanceID, `/\`) || strings.HasPrefix(instanceID, ".") {
+		return bosh.BoshManifest{}, fmt.Errorf("invalid instance id %q", instanceID)
+	}
+
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(s.Dir, instanceID+".yml"))
+	if err != nil {
+		return bosh.BoshManifest{}, errors.Wrap(err, "could not read manifest")
+	}
+
+	var manifest bosh.BoshManifest
+	if err := yaml.Unmarshal(manifestBytes, &manifest); err != nil {
+		return bosh.BoshManifest{}, errors.Wrap(err, "could not parse manifest")
+	}
+	return manifest, nil
+}
+
+type DashboardAuthenticator interface {
+	Authenticate(req *http.Request, client serviceadapter.ServiceInstanceUAAClient) error
+}
+
+// NoAuthAuthenticator lets every request through. It is meant for running the
+// dashboard against local fixtures only.
+type NoAuthAuthenticator struct{}
+
+func (NoAuthAuthenticator) Authenticate(*http.Request, serviceadapter.ServiceInstanceUAAClient) error {
+	return nil
+}
+
+// ErrDashboardForbidden is returned, possibly wrapped, by authenticators when
+// the request is authenticated but may not see the instance.
+var ErrDashboardForbidden = errors.New("token does not grant access to this instance")
+
+// ErrDashboardUnauthenticated is returned, possibly wrapped, by authenticators
+// when the request carries no credentials at all. Other failures are
+// forbidden, so that callers cannot tell unknown instances from known ones
+// by the status they get.
+var ErrDashboardUnauthenticated = errors.New("missing bearer token")
+
+// UAATokenAuthenticator checks the request's bearer token against UAA's
+// check_token endpoint, using the service instance client recorded in the
+// manifest as the resource server credentials. Only tokens issued for the
+// instance's client, i.e. with the client in their audience or a scope of
+// the client such as <client_id>.read, are accepted.
+type UAATokenAuthenticator struct {
+	UAAURL     string
+	HTTPClient *http.Client
+}
+
+func (a UAATokenAuthenticator) Authenticate(req *http.Request, client serviceadapter.ServiceInstanceUAAClient) error {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == req.Header.Get("Authorization") {
+		return ErrDashboardUnauthenticated
+	}
+	if client.ClientID == "" {
+		return errors.Wrap(ErrDashboardForbidden, "service instance has no UAA client")
+	}
+
+	checkReq, err := http.NewRequest(
+		http.MethodPost,
+		strings.TrimSuffix(a.UAAURL, "/")+"/check_token",
+		strings.NewReader(url.Values{"token": {token}}.Encode()),
+	)
+	if err != nil {
+		return err
+	}
+	checkReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	checkReq.SetBasicAuth(client.ClientID, client.ClientSecret)
+
+	httpClient := a.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(checkReq)
+	if err != nil {
+		return errors.Wrap(err, "could not reach UAA")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("UAA rejected token with status %d", resp.StatusCode)
+	}
+
+	var checkedToken struct {
+		Audience interface{} `json:"aud"`
+		Scope    []string    `json:"scope"`
+		ClientID string      `json:"client_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&checkedToken); err != nil {
+		return errors.Wrap(err, "could not parse UAA check_token response")
+	}
+
+	if containsString(stringList(checkedToken.Audience), client.ClientID) ||
+		(audienceString(checkedToken.Audience) == client.ClientID) {
+		return nil
+	}
+	for _, scope := range checkedToken.Scope {
+		if strings.HasPrefix(scope, client.ClientID+".") {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrDashboardForbidden, "token of client %s is not for client %s", checkedToken.ClientID, client.ClientID)
+}
+
+// audienceString is the audience of tokens that have a single one, which UAA
+// may send as a string rather than a list.
+func audienceString(audience interface{}) string {
+	single, _ := audience.(string)
+	return single
+}
+
+type DashboardServer struct {
+	Manifests     ManifestSource
+	Authenticator DashboardAuthenticator
+	StderrLogger  *log.Logger
+}
+
+type InstanceSummary struct {
+	InstanceID      string   `json:"instance_id"`
+	DeploymentName  string   `json:"deployment_name"`
+	InstanceGroup   string   `json:"instance_group"`
+	Instances       int      `json:"instances"`
+	VMType          string   `json:"vm_type"`
+	AZs             []string `json:"azs"`
+	Networks        []string `json:"networks"`
+	Port            int      `json:"port"`
+	Releases        []string `json:"releases"`
+	Persistence     string   `json:"persistence"`
+	MaxClients      string   `json:"maxclients"`
+	MaxMemory       string   `json:"maxmemory"`
+	MaxMemoryPolicy string   `json:"maxmemory_policy"`
+	BackupStatus    string   `json:"backup_status"`
+}
+
+func (d DashboardServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, dashboardInstancesPath) {
+		http.NotFound(w, req)
+		return
+	}
+	instanceID := strings.TrimPrefix(req.URL.Path, dashboardInstancesPath)
+	asJSON := strings.HasSuffix(instanceID, "/summary.json")
+	instanceID = strings.TrimSuffix(instanceID, "/summary.json")
+
+	d.StderrLogger = WithLogFields(d.StderrLogger, LogFields{Operation: "dashboard"})
+
+	// Unknown instances are authenticated too, against no client, so that
+	// they get the same status as known instances the caller may not see
+	manifest, manifestErr := d.Manifests.Manifest(instanceID)
+	var client serviceadapter.ServiceInstanceUAAClient
+	if manifestErr == nil {
+		client = serviceInstanceClientFromManifest(manifest)
+	}
+
+	if err := d.Authenticator.Authenticate(req, client); err != nil {
+		d.StderrLogger.Printf("dashboard: unauthorized request for %s: %s", instanceID, err)
+		if errors.Cause(err) == ErrDashboardUnauthenticated {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if manifestErr != nil {
+		d.StderrLogger.Printf("dashboard: could not load manifest for %s: %s", instanceID, manifestErr)
+		http.NotFound(w, req)
+		return
+	}
+
+	summary := SummarizeInstance(instanceID, manifest)
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, summary); err != nil {
+		d.StderrLogger.Printf("dashboard: could not render page for %s: %s", instanceID, err)
+	}
+}
+
+func SummarizeInstance(instanceID string, manifest bosh.BoshManifest) InstanceSummary {
+	summary := InstanceSummary{
+		InstanceID:      instanceID,
+		DeploymentName:  manifest.Name,
+		Port:            RedisServerPort,
+		Persistence:     "unknown",
+		MaxClients:      "not set",
+		MaxMemory:       "not set",
+		MaxMemoryPolicy: "not set",
+		BackupStatus:    "not configured",
+	}
+
+	for _, release := range manifest.Releases {
+		summary.Releases = append(summary.Releases, release.Name+"/"+release.Version)
+	}
+
+	if len(manifest.InstanceGroups) == 0 || len(manifest.InstanceGroups[0].Jobs) == 0 {
+		return summary
+	}
+	instanceGroup := manifest.InstanceGroups[0]
+	summary.InstanceGroup = instanceGroup.Name
+	summary.Instances = instanceGroup.Instances
+	summary.VMType = instanceGroup.VMType
+	summary.AZs = instanceGroup.AZs
+	for _, network := range instanceGroup.Networks {
+		summary.Networks = append(summary.Networks, network.Name)
+	}
+
+	properties := manifestRedisProperties(manifest)
+	if persistence, ok := properties["persistence"]; ok {
+		summary.Persistence = fmt.Sprint(persistence)
+	}
+	if maxClients, ok := properties["maxclients"]; ok {
+		summary.MaxClients = fmt.Sprint(maxClients)
+	}
+	if maxMemory, ok := properties["maxmemory"]; ok {
+		summary.MaxMemory = fmt.Sprint(maxMemory)
+	}
+	if policy, ok := properties["maxmemory-policy"]; ok {
+		summary.MaxMemoryPolicy = fmt.Sprint(policy)
+	}
+	if backup, ok := properties["backup"]; ok {
+		summary.BackupStatus = summarizeBackup(backup)
+	}
+
+	return summary
+}
+
+func summarizeBackup(backup interface{}) string {
+	backupProperties, ok := backup.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Sprint(backup)
+	}
+	var keys []string
+	for k, v := range backupProperties {
+		keys = append(keys, fmt.Sprintf("%v: %v", k, v))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// manifestRedisProperties is like redisPlanProperties but tolerates manifests
+// that have no redis properties at all.
+func manifestRedisProperties(manifest bosh.BoshManifest) map[interface{}]interface{} {
+	if len(manifest.InstanceGroups) == 0 || len(manifest.InstanceGroups[0].Jobs) == 0 {
+		return map[interface{}]interface{}{}
+	}
+	jobProperties, found := manifest.InstanceGroups[0].Jobs[0].Properties["redis"]
+	if !found {
+		jobProperties = manifest.InstanceGroups[0].Properties["redis"]
+	}
+	properties, ok := jobProperties.(map[interface{}]interface{})
+	if !ok {
+		return map[interface{}]interface{}{}
+	}
+	return properties
+}
+
+func serviceInstanceClientFromManifest(manifest bosh.BoshManifest) serviceadapter.ServiceInstanceUAAClient {
+	client := serviceadapter.ServiceInstanceUAAClient{}
+	clientDefinition := manifestRedisProperties(manifest)["service_instance_client"]
+
+	clientProperties := map[string]string{}
+	switch c := clientDefinition.(type) {
+	case map[string]string:
+		clientProperties = c
+	case map[interface{}]interface{}:
+		for k, v := range c {
+			clientProperties[fmt.Sprint(k)] = fmt.Sprint(v)
+		}
+	}
+
+	client.ClientID = clientProperties["client_id"]
+	client.ClientSecret = clientProperties["client_secret"]
+	client.Name = clientProperties["name"]
+	client.Scopes = clientProperties["scopes"]
+	client.Authorities = clientProperties["authorities"]
+	return client
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Redis instance {{.InstanceID}}</title></head>
+<body>
+<h1>Redis instance {{.InstanceID}}</h1>
+<h2>Connection</h2>
+<ul>
+<li>Deployment: {{.DeploymentName}}</li>
+<li>Port: {{.Port}}</li>
+<li>Instance group: {{.InstanceGroup}} ({{.Instances}} instances, {{.VMType}})</li>
+<li>Networks: {{range .Networks}}{{.}} {{end}}</li>
+<li>AZs: {{range .AZs}}{{.}} {{end}}</li>
+</ul>
+<h2>Configuration</h2>
+<ul>
+<li>Releases: {{range .Releases}}{{.}} {{end}}</li>
+<li>Persistence: {{.Persistence}}</li>
+<li>Max clients: {{.MaxClients}}</li>
+</ul>
+<h2>Memory</h2>
+<ul>
+<li>maxmemory: {{.MaxMemory}}</li>
+<li>maxmemory-policy: {{.MaxMemoryPolicy}}</li>
+</ul>
+<h2>Backups</h2>
+<p>{{.BackupStatus}}</p>
+</body>
+</html>
+`))
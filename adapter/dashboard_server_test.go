@@ -0,0 +1,154 @@
+package adapter_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+var _ = Describe("DashboardServer", func() {
+	var (
+		server   adapter.DashboardServer
+		recorder *httptest.ResponseRecorder
+		stderr   *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		stderr = gbytes.NewBuffer()
+		server = adapter.DashboardServer{
+			Manifests:     adapter.DirectoryManifestSource{Dir: filepath.Dir(getFixturePath("dashboard/some-instance-id.yml"))},
+			Authenticator: adapter.NoAuthAuthenticator{},
+			StderrLogger:  log.New(io.MultiWriter(stderr, GinkgoWriter), "", log.LstdFlags),
+		}
+		recorder = httptest.NewRecorder()
+	})
+
+	It("renders a page summarising the instance", func() {
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/instances/some-instance-id", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring("Persistence: yes"))
+		Expect(recorder.Body.String()).To(ContainSubstring("maxmemory: 512mb"))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("some-password"))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("some-client-secret"))
+	})
+
+	It("serves the summary as JSON", func() {
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/instances/some-instance-id/summary.json", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var summary adapter.InstanceSummary
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &summary)).To(Succeed())
+		Expect(summary.DeploymentName).To(Equal("service-instance_some-instance-id"))
+		Expect(summary.Port).To(Equal(adapter.RedisServerPort))
+		Expect(summary.Networks).To(ConsistOf("dedicated-network"))
+		Expect(summary.MaxClients).To(Equal("47"))
+		Expect(summary.MaxMemoryPolicy).To(Equal("not set"))
+		Expect(summary.BackupStatus).To(Equal("enabled: true"))
+	})
+
+	It("returns not found for unknown instances", func() {
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/instances/does-not-exist", nil))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects instance ids that escape the manifests directory", func() {
+		server.ServeHTTP(recorder, httptest.NewRequest("GET", "/instances/..%2Fbinding-config-invalid", nil))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	Describe("UAA authentication", func() {
+		var (
+			uaa           *httptest.Server
+			receivedToken string
+			receivedUser  string
+		)
+
+		BeforeEach(func() {
+			uaa = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/check_token"))
+				Expect(r.ParseForm()).To(Succeed())
+				receivedToken = r.Form.Get("token")
+				receivedUser, _, _ = r.BasicAuth()
+				switch receivedToken {
+				case "good-token":
+					w.Write([]byte(`{"client_id":"cf","aud":["cf","some-client-id"],"scope":["openid"]}`))
+				case "scoped-token":
+					w.Write([]byte(`{"client_id":"cf","aud":"cf","scope":["openid","some-client-id.read"]}`))
+				case "other-client-token":
+					w.Write([]byte(`{"client_id":"other-client-id","aud":["other-client-id","cf"],"scope":["other-client-id.read","some-client-id"]}`))
+				default:
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			}))
+			server.Authenticator = adapter.UAATokenAuthenticator{UAAURL: uaa.URL}
+		})
+
+		AfterEach(func() {
+			uaa.Close()
+		})
+
+		It("checks the token with the service instance client", func() {
+			req := httptest.NewRequest("GET", "/instances/some-instance-id", nil)
+			req.Header.Set("Authorization", "Bearer good-token")
+			server.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(receivedUser).To(Equal("some-client-id"))
+		})
+
+		It("accepts tokens with a scope of the service instance client", func() {
+			req := httptest.NewRequest("GET", "/instances/some-instance-id", nil)
+			req.Header.Set("Authorization", "Bearer scoped-token")
+			server.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("forbids valid tokens issued for other clients", func() {
+			req := httptest.NewRequest("GET", "/instances/some-instance-id", nil)
+			req.Header.Set("Authorization", "Bearer other-client-token")
+			server.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(stderr).To(gbytes.Say("token of client other-client-id is not for client some-client-id"))
+		})
+
+		It("rejects tokens UAA does not accept", func() {
+			req := httptest.NewRequest("GET", "/instances/some-instance-id", nil)
+			req.Header.Set("Authorization", "Bearer bad-token")
+			server.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(stderr).To(gbytes.Say("UAA rejected token with status 400"))
+		})
+
+		It("rejects requests without a token", func() {
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/instances/some-instance-id", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(stderr).To(gbytes.Say("missing bearer token"))
+		})
+
+		It("answers for unknown instances as for instances the token does not grant", func() {
+			server.ServeHTTP(recorder, httptest.NewRequest("GET", "/instances/does-not-exist", nil))
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+
+			for _, token := range []string{"good-token", "bad-token"} {
+				recorder = httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/instances/does-not-exist", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				server.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			}
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// DriftInputs are the files the check-drift subcommand compares.
+type DriftInputs struct {
+	DeployedManifestPath string
+	PlanPath             string
+	ParametersPath       string
+	PlanID               string
+}
+
+// Drift lists the properties and variables of a deployed manifest that differ
+// from what the adapter generates for it. From is the deployed value and To
+// the generated one.
+type Drift ManifestDiff
+
+// String describes each drifted value, e.g. one hand-edited during an
+// incident, in terms of the deployed manifest.
+func (d Drift) String() string {
+	if len(d) == 0 {
+		return "no drift\n"
+	}
+	var text strings.Builder
+	for _, entry := range d {
+		switch {
+		case strings.HasPrefix(entry.Path, "variables[") && entry.Change == ManifestValueAdded:
+			fmt.Fprintf(&text, "%s: missing from the deployed manifest\n", strings.TrimSuffix(entry.Path, ".name"))
+		case strings.HasPrefix(entry.Path, "variables[") && entry.Change == ManifestValueRemoved:
+			fmt.Fprintf(&text, "%s: not generated by the adapter\n", strings.TrimSuffix(entry.Path, ".name"))
+		case entry.Change == ManifestValueAdded:
+			fmt.Fprintf(&text, "%s: missing, the adapter generates %s\n", entry.Path, entry.To)
+		case entry.Change == ManifestValueRemoved:
+			fmt.Fprintf(&text, "%s: deployed %s, not generated by the adapter\n", entry.Path, entry.From)
+		default:
+			fmt.Fprintf(&text, "%s: deployed %s, the adapter generates %s\n", entry.Path, entry.From, entry.To)
+		}
+	}
+	fmt.Fprintf(&text, "%d drifted values\n", len(d))
+	return text.String()
+}
+
+// LoadDriftInputs builds the generate-manifest parameters the broker would
+// pass when upgrading the deployed manifest with the stored plan. The service
+// deployment is taken from the deployed manifest itself, so only the adapter's
+// own changes show up as drift.
+func LoadDriftInputs(inputs DriftInputs) (bosh.BoshManifest, serviceadapter.GenerateManifestParams, error) {
+	deployed, err := readManifest(inputs.DeployedManifestPath)
+	if err != nil {
+		return deployed, serviceadapter.GenerateManifestParams{}, fmt.Errorf("could not read deployed manifest: %s", err)
+	}
+
+	plan, err := readPlan(inputs.PlanPath)
+	if err != nil {
+		return deployed, serviceadapter.GenerateManifestParams{}, err
+	}
+
+	requestParams, err := readRequestParameters(inputs.ParametersPath, inputs.PlanID)
+	if err != nil {
+		return deployed, serviceadapter.GenerateManifestParams{}, err
+	}
+
+	return deployed, serviceadapter.GenerateManifestParams{
+		ServiceDeployment: ServiceDeploymentFromManifest(deployed),
+		Plan:              plan,
+		RequestParams:     requestParams,
+		PreviousManifest:  &deployed,
+	}, nil
+}
+
+// ServiceDeploymentFromManifest returns the releases and stemcells manifest
+// was deployed with. Each release provides the jobs that use it.
+func ServiceDeploymentFromManifest(manifest bosh.BoshManifest) serviceadapter.ServiceDeployment {
+	jobs := map[string][]string{}
+	for _, instanceGroup := range manifest.InstanceGroups {
+		for _, job := range instanceGroup.Jobs {
+			if !containsString(jobs[job.Release], job.Name) {
+				jobs[job.Release] = append(jobs[job.Release], job.Name)
+			}
+		}
+	}
+
+	deployment := serviceadapter.ServiceDeployment{DeploymentName: manifest.Name}
+	for _, release := range manifest.Releases {
+		deployment.Releases = append(deployment.Releases, serviceadapter.ServiceRelease{
+			Name:    release.Name,
+			Version: release.Version,
+			Jobs:    jobs[release.Name],
+		})
+	}
+	for _, stemcell := range manifest.Stemcells {
+		deployment.Stemcells = append(deployment.Stemcells, serviceadapter.Stemcell{
+			Name:    stemcell.Name,
+			OS:      stemcell.OS,
+			Version: stemcell.Version,
+		})
+	}
+	return deployment
+}
+
+// CheckDrift generates the manifest for params and reports the properties and
+// variables in deployed that differ from it.
+func CheckDrift(generator serviceadapter.ManifestGenerator, deployed bosh.BoshManifest, params serviceadapter.GenerateManifestParams) (Drift, error) {
+	output, err := generator.GenerateManifest(params)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := DiffManifests(deployed, output.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift Drift
+	for _, entry := range diff {
+		isVariable := strings.HasPrefix(entry.Path, "variables[")
+		isProperty := !isVariable && (strings.Contains(entry.Path, ".properties.") || strings.HasPrefix(entry.Path, "properties."))
+		isVariable = isVariable && strings.HasSuffix(entry.Path, "].name")
+		if isProperty || isVariable {
+			drift = append(drift, entry)
+		}
+	}
+	return drift, nil
+}
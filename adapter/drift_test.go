@@ -0,0 +1,93 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Drift", func() {
+	var (
+		generator adapter.ManifestGenerator
+		inputs    adapter.DriftInputs
+	)
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{Config: config, StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0)}
+		inputs = adapter.DriftInputs{
+			DeployedManifestPath: getFixturePath("render/deployed-manifest.yml"),
+			PlanPath:             getFixturePath("render/plan.yml"),
+		}
+	})
+
+	It("takes the service deployment from the deployed manifest", func() {
+		deployed, params, err := adapter.LoadDriftInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(params.PreviousManifest).To(Equal(&deployed))
+		Expect(params.ServiceDeployment).To(Equal(serviceadapter.ServiceDeployment{
+			DeploymentName: "service-instance_some-guid",
+			Releases: serviceadapter.ServiceReleases{
+				{Name: "redis", Version: "4", Jobs: []string{"redis-server", "health-check"}},
+			},
+			Stemcells: []serviceadapter.Stemcell{{OS: "ubuntu-jammy", Version: "1.1"}},
+		}))
+	})
+
+	It("reports hand-edited properties and missing variables", func() {
+		deployed, params, err := adapter.LoadDriftInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+
+		drift, err := adapter.CheckDrift(generator, deployed, params)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(drift).To(ConsistOf(
+			adapter.ManifestDiffEntry{
+				Path:   "instance_groups[redis-server].jobs[redis-server].properties.redis.maxmemory-policy",
+				Change: adapter.ManifestValueRemoved,
+				From:   "noeviction",
+			},
+			adapter.ManifestDiffEntry{
+				Path:   "instance_groups[redis-server].jobs[redis-server].properties.redis.persistence",
+				Change: adapter.ManifestValueChanged,
+				From:   "no",
+				To:     "yes",
+			},
+			adapter.ManifestDiffEntry{
+				Path:   "variables[secret_pass].name",
+				Change: adapter.ManifestValueAdded,
+				To:     "secret_pass",
+			},
+		))
+
+		text := drift.String()
+		Expect(text).To(ContainSubstring("properties.redis.maxmemory-policy: deployed noeviction, not generated by the adapter\n"))
+		Expect(text).To(ContainSubstring("properties.redis.persistence: deployed no, the adapter generates yes\n"))
+		Expect(text).To(ContainSubstring("variables[secret_pass]: missing from the deployed manifest\n"))
+		Expect(text).To(HaveSuffix("3 drifted values\n"))
+	})
+
+	It("reports no drift for a manifest the adapter generated", func() {
+		_, params, err := adapter.LoadDriftInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		drift, err := adapter.CheckDrift(generator, output.Manifest, withPreviousManifest(params, output.Manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift).To(BeEmpty())
+		Expect(drift.String()).To(Equal("no drift\n"))
+	})
+})
+
+func withPreviousManifest(params serviceadapter.GenerateManifestParams, manifest bosh.BoshManifest) serviceadapter.GenerateManifestParams {
+	params.PreviousManifest = &manifest
+	return params
+}
@@ -0,0 +1,123 @@
+package adapter
+
+import (
+	"errors"
+	"log"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// ErrorKind says who can act on an error.
+type ErrorKind string
+
+const (
+	// UserErrorKind errors are caused by what the Cloud Foundry user asked
+	// for, and tell them how to fix it.
+	UserErrorKind ErrorKind = "user"
+	// OperatorErrorKind errors are caused by the adapter, plan or release
+	// configuration. Users only learn that they should contact their operator.
+	OperatorErrorKind ErrorKind = "operator"
+	// TransientErrorKind errors are failures to reach a dependency, e.g. the
+	// service instance or CredHub, that are likely to go away on retry.
+	TransientErrorKind ErrorKind = "transient"
+	// InternalErrorKind errors are bugs in the adapter.
+	InternalErrorKind ErrorKind = "internal"
+)
+
+const (
+	OperatorErrorMessage  = "the service is not configured correctly for this request, please contact your operator"
+	TransientErrorMessage = "the service is temporarily unavailable, please try again later"
+	InternalErrorMessage  = "the service adapter failed unexpectedly, please contact your operator"
+)
+
+// AdapterError separates the message shown to Cloud Foundry users, which the
+// SDK writes to stdout for the broker to pass on, from the cause, which is only
+// logged for operators.
+type AdapterError struct {
+	Kind    ErrorKind
+	Message string
+	Cause   error
+}
+
+func (e AdapterError) Error() string {
+	return e.Message
+}
+
+func (e AdapterError) Unwrap() error {
+	return e.Cause
+}
+
+func NewUserError(cause error) error {
+	return AdapterError{Kind: UserErrorKind, Message: cause.Error(), Cause: cause}
+}
+
+func NewOperatorError(cause error) error {
+	return classify(OperatorErrorKind, OperatorErrorMessage, cause)
+}
+
+func NewTransientError(cause error) error {
+	return classify(TransientErrorKind, TransientErrorMessage, cause)
+}
+
+func NewInternalError(cause error) error {
+	return classify(InternalErrorKind, InternalErrorMessage, cause)
+}
+
+// classify wraps cause, unless it has already been classified closer to where
+// it happened.
+func classify(kind ErrorKind, message string, cause error) error {
+	var adapterErr AdapterError
+	if errors.As(cause, &adapterErr) {
+		return cause
+	}
+	return AdapterError{Kind: kind, Message: message, Cause: cause}
+}
+
+// ErrorKindOf returns the kind of err. Errors the adapter did not classify are
+// internal, except the SDK's binding errors, which are the user's to resolve.
+func ErrorKindOf(err error) ErrorKind {
+	var adapterErr AdapterError
+	if errors.As(err, &adapterErr) {
+		return adapterErr.Kind
+	}
+	switch err.(type) {
+	case serviceadapter.BindingAlreadyExistsError, serviceadapter.BindingNotFoundError, serviceadapter.AppGuidNotProvidedError:
+		return UserErrorKind
+	}
+	return InternalErrorKind
+}
+
+// ErrorDetail returns the full description of err for operators.
+func ErrorDetail(err error) string {
+	var adapterErr AdapterError
+	if errors.As(err, &adapterErr) && adapterErr.Cause != nil {
+		return adapterErr.Cause.Error()
+	}
+	return err.Error()
+}
+
+// ExitCode maps err to the exit codes the on-demand broker understands. Only
+// the binding errors have dedicated codes; everything else exits with
+// ErrorExitCode so the broker shows the user facing message.
+func ExitCode(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case serviceadapter.CLIHandlerError:
+		return e.ExitCode
+	case serviceadapter.BindingAlreadyExistsError:
+		return serviceadapter.BindingAlreadyExistsErrorExitCode
+	case serviceadapter.BindingNotFoundError:
+		return serviceadapter.BindingNotFoundErrorExitCode
+	case serviceadapter.AppGuidNotProvidedError:
+		return serviceadapter.AppGuidNotProvidedErrorExitCode
+	}
+	return serviceadapter.ErrorExitCode
+}
+
+func logAdapterError(logger *log.Logger, err error) {
+	if err == nil {
+		return
+	}
+	logger.Printf("[%s] %s error: %s", ErrorLogLevel, ErrorKindOf(err), ErrorDetail(err))
+}
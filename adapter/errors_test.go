@@ -0,0 +1,63 @@
+package adapter_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Errors", func() {
+	It("shows users their own mistakes", func() {
+		err := adapter.NewUserError(errors.New("unsupported parameter(s) for this service plan: foo"))
+		Expect(err).To(MatchError("unsupported parameter(s) for this service plan: foo"))
+		Expect(adapter.ErrorKindOf(err)).To(Equal(adapter.UserErrorKind))
+	})
+
+	It("hides the cause of operator errors from users", func() {
+		err := adapter.NewOperatorError(errors.New("no redis-server instance group definition found"))
+		Expect(err).To(MatchError(adapter.OperatorErrorMessage))
+		Expect(adapter.ErrorDetail(err)).To(Equal("no redis-server instance group definition found"))
+		Expect(errors.Unwrap(err)).To(MatchError("no redis-server instance group definition found"))
+	})
+
+	It("keeps the kind of errors that were already classified", func() {
+		err := adapter.NewInternalError(adapter.NewTransientError(errors.New("connection refused")))
+		Expect(adapter.ErrorKindOf(err)).To(Equal(adapter.TransientErrorKind))
+		Expect(err).To(MatchError(adapter.TransientErrorMessage))
+	})
+
+	It("treats unclassified errors as internal", func() {
+		Expect(adapter.ErrorKindOf(errors.New("boom"))).To(Equal(adapter.InternalErrorKind))
+		Expect(adapter.ErrorDetail(errors.New("boom"))).To(Equal("boom"))
+	})
+
+	DescribeTable("exit codes",
+		func(err error, exitCode int) {
+			Expect(adapter.ExitCode(err)).To(Equal(exitCode))
+		},
+		Entry("success", nil, 0),
+		Entry("user error", adapter.NewUserError(errors.New("nope")), serviceadapter.ErrorExitCode),
+		Entry("operator error", adapter.NewOperatorError(errors.New("nope")), serviceadapter.ErrorExitCode),
+		Entry("binding already exists", serviceadapter.NewBindingAlreadyExistsError(errors.New("nope")), serviceadapter.BindingAlreadyExistsErrorExitCode),
+		Entry("binding not found", serviceadapter.NewBindingNotFoundError(errors.New("nope")), serviceadapter.BindingNotFoundErrorExitCode),
+		Entry("SDK errors", serviceadapter.CLIHandlerError{ExitCode: serviceadapter.NotImplementedExitCode}, serviceadapter.NotImplementedExitCode),
+	)
+})
+
+// matchAdapterError matches errors of the given kind whose operator facing
+// detail matches detail, a string or a matcher.
+func matchAdapterError(kind adapter.ErrorKind, detail interface{}) types.GomegaMatcher {
+	detailMatcher, ok := detail.(types.GomegaMatcher)
+	if !ok {
+		detailMatcher = Equal(detail)
+	}
+	return SatisfyAll(
+		WithTransform(adapter.ErrorKindOf, Equal(kind)),
+		WithTransform(adapter.ErrorDetail, detailMatcher),
+	)
+}
@@ -0,0 +1,86 @@
+package adapter
+
+import (
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// Hooks customize what the adapter generates without changing the adapter
+// package. They are registered in main.go, or in the main package of a team
+// building its own adapter binary on top of this package, and run in the
+// order they are registered.
+type Hooks struct {
+	// Parameters are the arbitrary parameters accepted, and listed in plan
+	// schemas, in addition to those of the service definition, for the hooks
+	// to use.
+	Parameters []string
+
+	// ParameterValidators check the arbitrary parameters of instance creates
+	// and updates. Their errors are shown to users.
+	ParameterValidators []ParameterValidator
+
+	// ManifestCustomizers change generated manifests, which are then
+	// validated. Their errors are only shown to operators.
+	ManifestCustomizers []ManifestCustomizer
+
+	// CredentialDecorators change the credentials of new bindings before they
+	// are returned, or stored in CredHub. Their errors are only shown to
+	// operators.
+	CredentialDecorators []CredentialDecorator
+}
+
+type ParameterValidator interface {
+	ValidateParameters(parameters map[string]interface{}, plan serviceadapter.Plan) error
+}
+
+type ManifestCustomizer interface {
+	CustomizeManifest(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error
+}
+
+type CredentialDecorator interface {
+	DecorateCredentials(params serviceadapter.CreateBindingParams, credentials map[string]interface{}) error
+}
+
+type ParameterValidatorFunc func(parameters map[string]interface{}, plan serviceadapter.Plan) error
+
+func (f ParameterValidatorFunc) ValidateParameters(parameters map[string]interface{}, plan serviceadapter.Plan) error {
+	return f(parameters, plan)
+}
+
+type ManifestCustomizerFunc func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error
+
+func (f ManifestCustomizerFunc) CustomizeManifest(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+	return f(params, output)
+}
+
+type CredentialDecoratorFunc func(params serviceadapter.CreateBindingParams, credentials map[string]interface{}) error
+
+func (f CredentialDecoratorFunc) DecorateCredentials(params serviceadapter.CreateBindingParams, credentials map[string]interface{}) error {
+	return f(params, credentials)
+}
+
+func (h Hooks) validateParameters(parameters map[string]interface{}, plan serviceadapter.Plan) error {
+	for _, validator := range h.ParameterValidators {
+		if err := validator.ValidateParameters(parameters, plan); err != nil {
+			return classify(UserErrorKind, err.Error(), err)
+		}
+	}
+	return nil
+}
+
+func (h Hooks) customizeManifest(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+	for _, customizer := range h.ManifestCustomizers {
+		if err := customizer.CustomizeManifest(params, output); err != nil {
+			return NewOperatorError(err)
+		}
+	}
+	return nil
+}
+
+func (h Hooks) decorateCredentials(params serviceadapter.CreateBindingParams, credentials map[string]interface{}) error {
+	for _, decorator := range h.CredentialDecorators {
+		if err := decorator.DecorateCredentials(params, credentials); err != nil {
+			return NewOperatorError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package adapter_test
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Hooks", func() {
+	Describe("when generating manifests", func() {
+		var (
+			generator adapter.ManifestGenerator
+			params    serviceadapter.GenerateManifestParams
+		)
+
+		BeforeEach(func() {
+			config := adapter.Config{}
+			config.ApplyDefaults()
+			generator = adapter.ManifestGenerator{
+				Config:       config,
+				StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0),
+			}
+
+			var err error
+			params, err = adapter.LoadRenderInputs(adapter.RenderInputs{
+				PlanPath:              getFixturePath("render/plan.yml"),
+				ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("runs manifest customizers in order", func() {
+			generator.Hooks.ManifestCustomizers = []adapter.ManifestCustomizer{
+				adapter.ManifestCustomizerFunc(func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+					output.Manifest.Tags["team"] = "data"
+					return nil
+				}),
+				adapter.ManifestCustomizerFunc(func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+					output.Manifest.Tags["team"] = output.Manifest.Tags["team"].(string) + "-platform"
+					output.Manifest.InstanceGroups[0].Jobs[0].Properties["syslog"] = "syslog.example.com"
+					return nil
+				}),
+			}
+
+			output, err := generator.GenerateManifest(params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output.Manifest.Tags).To(HaveKeyWithValue("team", "data-platform"))
+			Expect(output.Manifest.InstanceGroups[0].Jobs[0].Properties).To(HaveKeyWithValue("syslog", "syslog.example.com"))
+		})
+
+		It("validates customized manifests", func() {
+			generator.Hooks.ManifestCustomizers = []adapter.ManifestCustomizer{
+				adapter.ManifestCustomizerFunc(func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+					output.Manifest.InstanceGroups[0].Jobs = append(output.Manifest.InstanceGroups[0].Jobs, bosh.Job{Name: "syslog-forwarder", Release: "syslog"})
+					return nil
+				}),
+			}
+
+			_, err := generator.GenerateManifest(params)
+			Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, ContainSubstring(`job syslog-forwarder uses undeclared release "syslog"`)))
+		})
+
+		It("only shows manifest customizer errors to operators", func() {
+			generator.Hooks.ManifestCustomizers = []adapter.ManifestCustomizer{
+				adapter.ManifestCustomizerFunc(func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+					return errors.New("no syslog configured")
+				}),
+			}
+
+			_, err := generator.GenerateManifest(params)
+			Expect(err).To(MatchError(adapter.OperatorErrorMessage))
+			Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "no syslog configured"))
+		})
+
+		It("accepts the hooks' parameters and shows validation errors to users", func() {
+			generator.Hooks.Parameters = []string{"team"}
+			generator.Hooks.ParameterValidators = []adapter.ParameterValidator{
+				adapter.ParameterValidatorFunc(func(parameters map[string]interface{}, plan serviceadapter.Plan) error {
+					if _, ok := parameters["team"].(string); !ok {
+						return errors.New("team must be a string")
+					}
+					return nil
+				}),
+			}
+
+			params.RequestParams = map[string]interface{}{"parameters": map[string]interface{}{"team": "data"}}
+			_, err := generator.GenerateManifest(params)
+			Expect(err).NotTo(HaveOccurred())
+
+			params.RequestParams = map[string]interface{}{"parameters": map[string]interface{}{"team": 1}}
+			_, err = generator.GenerateManifest(params)
+			Expect(err).To(matchAdapterError(adapter.UserErrorKind, "team must be a string"))
+			Expect(err).To(MatchError("team must be a string"))
+		})
+	})
+
+	Describe("when creating bindings", func() {
+		var (
+			binder   adapter.Binder
+			registry *fakeBindingRegistry
+			params   serviceadapter.CreateBindingParams
+		)
+
+		BeforeEach(func() {
+			registry = &fakeBindingRegistry{bindings: map[string]string{}}
+			binder = adapter.Binder{
+				StderrLogger: log.New(GinkgoWriter, "", 0),
+				Bindings:     registry,
+			}
+			params = serviceadapter.CreateBindingParams{
+				BindingID:          "binding-id",
+				DeploymentTopology: bosh.BoshVMs{"redis-server": []string{"127.0.0.1"}},
+				Manifest: bosh.BoshManifest{
+					InstanceGroups: []bosh.InstanceGroup{{
+						Jobs: []bosh.Job{{
+							Properties: map[string]interface{}{
+								"redis": map[interface{}]interface{}{"password": "supersecret"},
+							},
+						}},
+					}},
+				},
+			}
+		})
+
+		It("decorates the binding credentials", func() {
+			binder.Hooks.CredentialDecorators = []adapter.CredentialDecorator{
+				adapter.CredentialDecoratorFunc(func(params serviceadapter.CreateBindingParams, credentials map[string]interface{}) error {
+					credentials["binding_id"] = params.BindingID
+					delete(credentials, "dns_addresses")
+					return nil
+				}),
+			}
+
+			binding, err := binder.CreateBinding(params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(binding.Credentials).To(HaveKeyWithValue("binding_id", "binding-id"))
+			Expect(binding.Credentials).NotTo(HaveKey("dns_addresses"))
+		})
+
+		It("removes the binding when a decorator fails", func() {
+			binder.Hooks.CredentialDecorators = []adapter.CredentialDecorator{
+				adapter.CredentialDecoratorFunc(func(params serviceadapter.CreateBindingParams, credentials map[string]interface{}) error {
+					return errors.New("could not register binding")
+				}),
+			}
+
+			_, err := binder.CreateBinding(params)
+			Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "could not register binding"))
+			Expect(registry.bindings).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,221 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const (
+	TextLogFormat = "text"
+	JSONLogFormat = "json"
+
+	DebugLogLevel = "debug"
+	InfoLogLevel  = "info"
+	WarnLogLevel  = "warn"
+	ErrorLogLevel = "error"
+
+	textLogPrefix = "[redis-service-adapter] "
+)
+
+var logLevels = map[string]int{
+	DebugLogLevel: 0,
+	InfoLogLevel:  1,
+	WarnLogLevel:  2,
+	ErrorLogLevel: 3,
+}
+
+// LogFields correlate the lines logged while handling one operation.
+type LogFields struct {
+	Operation  string `json:"operation,omitempty"`
+	Deployment string `json:"deployment,omitempty"`
+	BindingID  string `json:"binding_id,omitempty"`
+	Plan       string `json:"plan,omitempty"`
+}
+
+func (f LogFields) merge(other LogFields) LogFields {
+	if other.Operation != "" {
+		f.Operation = other.Operation
+	}
+	if other.Deployment != "" {
+		f.Deployment = other.Deployment
+	}
+	if other.BindingID != "" {
+		f.BindingID = other.BindingID
+	}
+	if other.Plan != "" {
+		f.Plan = other.Plan
+	}
+	return f
+}
+
+// LogWriter is the output of the adapter's *log.Logger. It filters lines by
+// level, redacts anything that looks like a secret and writes either JSON
+// lines or plain text. Lines are logged at info level unless they start with a
+// level marker such as "[debug] ".
+type LogWriter struct {
+	Out    io.Writer
+	Format string
+	Level  string
+	Fields LogFields
+	Now    func() time.Time
+
+	mu *sync.Mutex
+}
+
+type logLine struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	LogFields
+}
+
+// NewLogger returns a logger writing to out in the given format, dropping lines
+// below level.
+func NewLogger(out io.Writer, format, level string) *log.Logger {
+	return log.New(&LogWriter{Out: out, Format: format, Level: level, mu: &sync.Mutex{}}, "", 0)
+}
+
+// WithLogFields returns a logger that adds fields to every line. Loggers not
+// created by NewLogger are returned unchanged.
+func WithLogFields(logger *log.Logger, fields LogFields) *log.Logger {
+	if logger == nil {
+		return nil
+	}
+	writer, ok := logger.Writer().(*LogWriter)
+	if !ok {
+		return logger
+	}
+	withFields := *writer
+	withFields.Fields = writer.Fields.merge(fields)
+	return log.New(&withFields, "", 0)
+}
+
+func (w *LogWriter) Write(p []byte) (int, error) {
+	level, message := splitLogLevel(strings.TrimSuffix(string(p), "\n"))
+	if logLevels[level] < logLevels[w.Level] {
+		return len(p), nil
+	}
+	message = RedactSecrets(message)
+
+	now := time.Now
+	if w.Now != nil {
+		now = w.Now
+	}
+
+	var line []byte
+	if w.Format == TextLogFormat {
+		line = []byte(textLogPrefix + now().Format("2006/01/02 15:04:05") + " " + message + w.textFields() + "\n")
+	} else {
+		var err error
+		line, err = json.Marshal(logLine{
+			Timestamp: now().UTC().Format(time.RFC3339Nano),
+			Level:     level,
+			Message:   message,
+			LogFields: w.Fields,
+		})
+		if err != nil {
+			return 0, err
+		}
+		line = append(line, '\n')
+	}
+
+	if w.mu != nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+	}
+	if _, err := w.Out.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *LogWriter) textFields() string {
+	fields := map[string]string{
+		"operation":  w.Fields.Operation,
+		"deployment": w.Fields.Deployment,
+		"binding_id": w.Fields.BindingID,
+		"plan":       w.Fields.Plan,
+	}
+	var keys []string
+	for key, value := range fields {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var text string
+	for _, key := range keys {
+		text += fmt.Sprintf(" %s=%s", key, fields[key])
+	}
+	return text
+}
+
+func splitLogLevel(message string) (string, string) {
+	for level := range logLevels {
+		marker := "[" + level + "] "
+		if strings.HasPrefix(message, marker) {
+			return level, strings.TrimPrefix(message, marker)
+		}
+	}
+	return InfoLogLevel, message
+}
+
+// planID returns the plan the broker passed in the request parameters.
+func planID(requestParams serviceadapter.RequestParameters) string {
+	id, _ := requestParams["plan_id"].(string)
+	return id
+}
+
+func debugf(logger *log.Logger, format string, args ...interface{}) {
+	logger.Printf("["+DebugLogLevel+"] "+format, args...)
+}
+
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{
+		pattern:     regexp.MustCompile(`-----BEGIN [A-Z ]+-----[\s\S]*?-----END [A-Z ]+-----`),
+		replacement: "<redacted certificate>",
+	},
+	// resolved ((credhub)) references, e.g. a %v of the secrets map
+	{
+		pattern:     regexp.MustCompile(`(\(\([^()]+\)\)"?\s*[:=]\s*)"[^"]*"`),
+		replacement: `${1}"<redacted>"`,
+	},
+	{
+		pattern:     regexp.MustCompile(`(\(\([^()]+\)\)"?\s*[:=]\s*)[^\s,}\]"]+`),
+		replacement: "${1}<redacted>",
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)("?[a-z_]*(?:password|secret|token|private_key)"?\s*[:=]\s*)"[^"]*"`),
+		replacement: `${1}"<redacted>"`,
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)("?[a-z_]*(?:password|secret|token|private_key)"?\s*[:=]\s*)[^\s,}\]"]+`),
+		replacement: "${1}<redacted>",
+	},
+	{
+		pattern:     regexp.MustCompile(`(rediss?://[^:/@\s]*:)[^@\s]+@`),
+		replacement: "${1}<redacted>@",
+	},
+}
+
+// RedactSecrets hides passwords, certificates and resolved CredHub values in
+// a log message.
+func RedactSecrets(message string) string {
+	for _, secret := range secretPatterns {
+		message = secret.pattern.ReplaceAllString(message, secret.replacement)
+	}
+	return message
+}
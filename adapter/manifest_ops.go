@@ -0,0 +1,289 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	ReplaceManifestOp = "replace"
+	RemoveManifestOp  = "remove"
+)
+
+// ManifestOp patches generated manifests like an operation in a BOSH ops file,
+// e.g.
+//
+//	type: replace
+//	path: /instance_groups/name=redis-server/env?/bosh/swap_size
+//	value: 0
+//
+// Paths use the ops file syntax: /key, /0, /- to append, /name=value to find
+// an item in a list, and a trailing ? to create what is missing. When is
+// optional; the operation is then only applied to plans whose properties
+// have all the listed values.
+type ManifestOp struct {
+	Type  string                 `yaml:"type"`
+	Path  string                 `yaml:"path"`
+	Value interface{}            `yaml:"value,omitempty"`
+	When  map[string]interface{} `yaml:"when,omitempty"`
+}
+
+// ValidateManifestOps reports every malformed operation at once.
+func ValidateManifestOps(ops []ManifestOp) error {
+	var problems []string
+	for i, op := range ops {
+		problems = append(problems, checkOneOf(fmt.Sprintf("manifest_ops[%d].type", i), op.Type, ReplaceManifestOp, RemoveManifestOp)...)
+		if _, err := parseOpPath(op.Path); err != nil {
+			problems = append(problems, fmt.Sprintf("manifest_ops[%d].path %s", i, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ApplyManifestOps applies the operations whose conditions plan meets to
+// manifest, in order.
+func ApplyManifestOps(manifest bosh.BoshManifest, ops []ManifestOp, plan serviceadapter.Plan) (bosh.BoshManifest, error) {
+	var applicable []ManifestOp
+	for _, op := range ops {
+		if op.appliesTo(plan) {
+			applicable = append(applicable, op)
+		}
+	}
+	if len(applicable) == 0 {
+		return manifest, nil
+	}
+
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return bosh.BoshManifest{}, err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(manifestBytes, &document); err != nil {
+		return bosh.BoshManifest{}, err
+	}
+
+	for _, op := range applicable {
+		document, err = op.apply(document)
+		if err != nil {
+			return bosh.BoshManifest{}, fmt.Errorf("could not %s %s: %s", op.Type, op.Path, err)
+		}
+	}
+
+	manifestBytes, err = yaml.Marshal(document)
+	if err != nil {
+		return bosh.BoshManifest{}, err
+	}
+	var patched bosh.BoshManifest
+	if err := yaml.UnmarshalStrict(manifestBytes, &patched); err != nil {
+		return bosh.BoshManifest{}, fmt.Errorf("manifest ops produced a manifest the adapter cannot represent: %s", err)
+	}
+	return patched, nil
+}
+
+func (op ManifestOp) appliesTo(plan serviceadapter.Plan) bool {
+	for key, value := range op.When {
+		planValue, found := plan.Properties[key]
+		if !found || fmt.Sprint(planValue) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (op ManifestOp) apply(document interface{}) (interface{}, error) {
+	tokens, err := parseOpPath(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		if op.Type == RemoveManifestOp {
+			return nil, fmt.Errorf("cannot remove the whole manifest")
+		}
+		return op.Value, nil
+	}
+	return op.patch(document, tokens)
+}
+
+// patch returns node with the operation applied at the path tokens lead to.
+func (op ManifestOp) patch(node interface{}, tokens []opPathToken) (interface{}, error) {
+	token, rest := tokens[0], tokens[1:]
+	last := len(rest) == 0
+
+	switch current := node.(type) {
+	case map[interface{}]interface{}:
+		if token.kind != opKeyToken {
+			return nil, fmt.Errorf("expected a list at %s, found a map", token)
+		}
+		child, found := current[token.key]
+		if last {
+			if op.Type == RemoveManifestOp {
+				if !found && !token.optional {
+					return nil, fmt.Errorf("no key %q", token.key)
+				}
+				delete(current, token.key)
+				return current, nil
+			}
+			if !found && !token.optional {
+				return nil, fmt.Errorf("no key %q", token.key)
+			}
+			current[token.key] = op.Value
+			return current, nil
+		}
+		if !found {
+			if !token.optional {
+				return nil, fmt.Errorf("no key %q", token.key)
+			}
+			child = emptyContainerFor(rest[0])
+		}
+		patched, err := op.patch(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		current[token.key] = patched
+		return current, nil
+
+	case []interface{}:
+		index, found, err := token.findIn(current)
+		if err != nil {
+			return nil, err
+		}
+		if token.kind == opAppendToken {
+			if !last || op.Type == RemoveManifestOp {
+				return nil, fmt.Errorf("- can only be used to append with replace as the last element of a path")
+			}
+			return append(current, op.Value), nil
+		}
+		if !found {
+			if !token.optional || token.kind != opMatchToken {
+				return nil, fmt.Errorf("no item %s", token)
+			}
+			if op.Type == RemoveManifestOp && last {
+				return current, nil
+			}
+			current = append(current, map[interface{}]interface{}{token.key: token.value})
+			index = len(current) - 1
+		}
+		if last {
+			if op.Type == RemoveManifestOp {
+				return append(current[:index:index], current[index+1:]...), nil
+			}
+			current[index] = op.Value
+			return current, nil
+		}
+		patched, err := op.patch(current[index], rest)
+		if err != nil {
+			return nil, err
+		}
+		current[index] = patched
+		return current, nil
+
+	case nil:
+		if !token.optional {
+			return nil, fmt.Errorf("nothing found at %s", token)
+		}
+		return op.patch(emptyContainerFor(token), tokens)
+	}
+	return nil, fmt.Errorf("cannot follow %s into %v", token, node)
+}
+
+func emptyContainerFor(token opPathToken) interface{} {
+	if token.kind == opKeyToken {
+		return map[interface{}]interface{}{}
+	}
+	return []interface{}{}
+}
+
+type opPathTokenKind int
+
+const (
+	opKeyToken opPathTokenKind = iota
+	opIndexToken
+	opAppendToken
+	opMatchToken
+)
+
+type opPathToken struct {
+	kind     opPathTokenKind
+	key      string
+	value    string
+	index    int
+	optional bool
+}
+
+func (t opPathToken) String() string {
+	switch t.kind {
+	case opIndexToken:
+		return strconv.Itoa(t.index)
+	case opAppendToken:
+		return "-"
+	case opMatchToken:
+		return t.key + "=" + t.value
+	}
+	return t.key
+}
+
+func (t opPathToken) findIn(list []interface{}) (int, bool, error) {
+	switch t.kind {
+	case opIndexToken:
+		index := t.index
+		if index < 0 {
+			index += len(list)
+		}
+		return index, index >= 0 && index < len(list), nil
+	case opMatchToken:
+		for i, item := range list {
+			if fields, ok := item.(map[interface{}]interface{}); ok && fmt.Sprint(fields[t.key]) == t.value {
+				return i, true, nil
+			}
+		}
+		return 0, false, nil
+	case opAppendToken:
+		return len(list), false, nil
+	}
+	return 0, false, fmt.Errorf("expected a map at %s, found a list", t)
+}
+
+// parseOpPath splits an ops file path into tokens. As in BOSH ops files, every
+// token after one marked optional with ? is optional too.
+func parseOpPath(path string) ([]opPathToken, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("must start with /, got %q", path)
+	}
+	if path == "/" {
+		return nil, nil
+	}
+
+	var tokens []opPathToken
+	optional := false
+	for _, segment := range strings.Split(path[1:], "/") {
+		if strings.HasSuffix(segment, "?") {
+			optional = true
+			segment = strings.TrimSuffix(segment, "?")
+		}
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		if segment == "" {
+			return nil, fmt.Errorf("has an empty element in %q", path)
+		}
+
+		token := opPathToken{kind: opKeyToken, key: segment, optional: optional}
+		if segment == "-" {
+			token.kind = opAppendToken
+		} else if index, err := strconv.Atoi(segment); err == nil {
+			token.kind = opIndexToken
+			token.index = index
+		} else if parts := strings.SplitN(segment, "=", 2); len(parts) == 2 {
+			token.kind = opMatchToken
+			token.key, token.value = parts[0], parts[1]
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
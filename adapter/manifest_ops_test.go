@@ -0,0 +1,140 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Manifest ops", func() {
+	var (
+		manifest bosh.BoshManifest
+		plan     serviceadapter.Plan
+	)
+
+	BeforeEach(func() {
+		manifest = bosh.BoshManifest{
+			Name:     "some-deployment",
+			Releases: []bosh.Release{{Name: "redis", Version: "4"}},
+			InstanceGroups: []bosh.InstanceGroup{
+				{
+					Name:      "redis-server",
+					Instances: 1,
+					Jobs: []bosh.Job{{
+						Name:       "redis-server",
+						Release:    "redis",
+						Properties: map[string]interface{}{"redis": map[interface{}]interface{}{"maxclients": 100}},
+					}},
+					Networks: []bosh.Network{{Name: "some-network"}},
+				},
+				{Name: "health-check", Instances: 1, Lifecycle: adapter.LifecycleErrandType},
+			},
+			Tags: map[string]interface{}{"product": "redis"},
+		}
+		plan = serviceadapter.Plan{Properties: serviceadapter.Properties{"persistence": true}}
+	})
+
+	apply := func(ops ...adapter.ManifestOp) bosh.BoshManifest {
+		patched, err := adapter.ApplyManifestOps(manifest, ops, plan)
+		Expect(err).NotTo(HaveOccurred())
+		return patched
+	}
+
+	It("creates missing values along optional paths", func() {
+		patched := apply(adapter.ManifestOp{
+			Type:  adapter.ReplaceManifestOp,
+			Path:  "/instance_groups/name=redis-server/env?/bosh/swap_size",
+			Value: 0,
+		})
+		Expect(patched.InstanceGroups[0].Env).To(Equal(map[string]interface{}{
+			"bosh": map[interface{}]interface{}{"swap_size": 0},
+		}))
+	})
+
+	It("replaces and removes values", func() {
+		patched := apply(
+			adapter.ManifestOp{
+				Type:  adapter.ReplaceManifestOp,
+				Path:  "/instance_groups/name=redis-server/jobs/name=redis-server/properties/redis/maxclients",
+				Value: 200,
+			},
+			adapter.ManifestOp{Type: adapter.RemoveManifestOp, Path: "/instance_groups/name=health-check"},
+			adapter.ManifestOp{Type: adapter.RemoveManifestOp, Path: "/tags/product"},
+		)
+		Expect(patched.InstanceGroups).To(HaveLen(1))
+		Expect(patched.InstanceGroups[0].Jobs[0].Properties["redis"]).To(HaveKeyWithValue("maxclients", 200))
+		Expect(patched.Tags).To(BeEmpty())
+	})
+
+	It("appends to lists", func() {
+		patched := apply(adapter.ManifestOp{
+			Type:  adapter.ReplaceManifestOp,
+			Path:  "/instance_groups/0/networks/-",
+			Value: map[interface{}]interface{}{"name": "backup-network"},
+		})
+		Expect(patched.InstanceGroups[0].Networks).To(Equal([]bosh.Network{{Name: "some-network"}, {Name: "backup-network"}}))
+	})
+
+	It("only applies operations to plans meeting their conditions", func() {
+		op := adapter.ManifestOp{
+			Type:  adapter.ReplaceManifestOp,
+			Path:  "/instance_groups/name=redis-server/vm_extensions?/-",
+			Value: "fast-disks",
+			When:  map[string]interface{}{"persistence": true},
+		}
+		Expect(apply(op).InstanceGroups[0].VMExtensions).To(Equal([]string{"fast-disks"}))
+
+		plan.Properties["persistence"] = false
+		Expect(apply(op)).To(Equal(manifest))
+	})
+
+	DescribeTable("rejects operations that cannot be applied",
+		func(op adapter.ManifestOp, expectedErr interface{}) {
+			_, err := adapter.ApplyManifestOps(manifest, []adapter.ManifestOp{op}, plan)
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("missing keys", adapter.ManifestOp{Type: adapter.ReplaceManifestOp, Path: "/instance_groups/0/env/bosh", Value: 1},
+			`could not replace /instance_groups/0/env/bosh: no key "env"`),
+		Entry("missing items", adapter.ManifestOp{Type: adapter.RemoveManifestOp, Path: "/instance_groups/name=cleanup-data"},
+			"could not remove /instance_groups/name=cleanup-data: no item name=cleanup-data"),
+		Entry("fields the manifest does not have", adapter.ManifestOp{Type: adapter.ReplaceManifestOp, Path: "/instance_groups/0/colour?", Value: "red"},
+			ContainSubstring("manifest ops produced a manifest the adapter cannot represent")),
+	)
+
+	It("reports malformed operations", func() {
+		err := adapter.ValidateManifestOps([]adapter.ManifestOp{
+			{Type: "move", Path: "/name"},
+			{Type: adapter.RemoveManifestOp, Path: "name"},
+			{Type: adapter.RemoveManifestOp, Path: "/instance_groups//name"},
+		})
+		Expect(err).To(MatchError(`manifest_ops[0].type must be one of replace, remove, got "move"; ` +
+			`manifest_ops[1].path must start with /, got "name"; ` +
+			`manifest_ops[2].path has an empty element in "/instance_groups//name"`))
+	})
+
+	It("applies the configured operations to generated manifests", func() {
+		config := adapter.Config{ManifestOps: []adapter.ManifestOp{{
+			Type:  adapter.ReplaceManifestOp,
+			Path:  "/instance_groups/name=redis-server/env?/persistent_disk_fs",
+			Value: "xfs",
+		}}}
+		config.ApplyDefaults()
+		generator := adapter.ManifestGenerator{Config: config, StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0)}
+
+		params, err := adapter.LoadRenderInputs(adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Manifest.InstanceGroups[0].Env).To(HaveKeyWithValue("persistent_disk_fs", "xfs"))
+	})
+})
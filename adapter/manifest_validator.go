@@ -0,0 +1,174 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var variableReferencePattern = regexp.MustCompile(`\(\(([^()]+)\)\)`)
+
+// ValidateManifest checks the rules BOSH would otherwise reject manifest for
+// only at deploy time: unique names, declared releases and stemcells, placed
+// instance groups, links consumed by variables, and ((variable)) references
+// that are declared, ODB managed secrets in secrets, or absolute CredHub paths.
+// Every problem is reported at once.
+func ValidateManifest(manifest bosh.BoshManifest, secrets serviceadapter.ODBManagedSecrets) error {
+	var problems []string
+
+	if manifest.Name == "" {
+		problems = append(problems, "name must not be blank")
+	}
+
+	releases := map[string]bool{}
+	for _, release := range manifest.Releases {
+		if releases[release.Name] {
+			problems = append(problems, fmt.Sprintf("release %s is declared more than once", release.Name))
+		}
+		releases[release.Name] = true
+	}
+
+	stemcells := map[string]bool{}
+	for _, stemcell := range manifest.Stemcells {
+		if stemcells[stemcell.Alias] {
+			problems = append(problems, fmt.Sprintf("stemcell %s is declared more than once", stemcell.Alias))
+		}
+		stemcells[stemcell.Alias] = true
+	}
+
+	if len(manifest.InstanceGroups) == 0 {
+		problems = append(problems, "no instance groups")
+	}
+	// BOSH directors without AZs in their cloud config take instance groups
+	// without azs, but one director cannot place some groups in AZs and
+	// others outside them
+	azsRequired := false
+	for _, instanceGroup := range manifest.InstanceGroups {
+		azsRequired = azsRequired || len(instanceGroup.AZs) > 0
+	}
+
+	instanceGroups := map[string]bool{}
+	providers := map[string]bool{}
+	for _, instanceGroup := range manifest.InstanceGroups {
+		if instanceGroups[instanceGroup.Name] {
+			problems = append(problems, fmt.Sprintf("instance group %s is declared more than once", instanceGroup.Name))
+		}
+		instanceGroups[instanceGroup.Name] = true
+		problems = append(problems, instanceGroupProblems(instanceGroup, releases, stemcells, azsRequired)...)
+
+		for _, job := range instanceGroup.Jobs {
+			for _, provider := range job.CustomProviderDefinitions {
+				providers[provider.Name] = true
+			}
+		}
+	}
+
+	variables := map[string]bool{}
+	for _, variable := range manifest.Variables {
+		if variables[variable.Name] {
+			problems = append(problems, fmt.Sprintf("variable %s is declared more than once", variable.Name))
+		}
+		variables[variable.Name] = true
+
+		if variable.Consumes == nil {
+			continue
+		}
+		for _, link := range []bosh.VariableConsumesLink{variable.Consumes.CommonName, variable.Consumes.AlternativeName} {
+			if link.From != "" && !providers[link.From] {
+				problems = append(problems, fmt.Sprintf("variable %s consumes link %s, which no job provides", variable.Name, link.From))
+			}
+		}
+	}
+
+	references, err := variableReferences(manifest)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("could not read variable references: %s", err))
+	}
+	for _, reference := range references {
+		if problem := variableReferenceProblem(reference, variables, secrets); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func instanceGroupProblems(instanceGroup bosh.InstanceGroup, releases, stemcells map[string]bool, azsRequired bool) []string {
+	var problems []string
+	prefix := fmt.Sprintf("instance group %s", instanceGroup.Name)
+
+	if instanceGroup.Name == "" {
+		problems = append(problems, "an instance group has no name")
+	}
+	if !stemcells[instanceGroup.Stemcell] {
+		problems = append(problems, fmt.Sprintf("%s uses undeclared stemcell %q", prefix, instanceGroup.Stemcell))
+	}
+	if instanceGroup.Instances < 0 {
+		problems = append(problems, fmt.Sprintf("%s has %d instances", prefix, instanceGroup.Instances))
+	}
+	if azsRequired && len(instanceGroup.AZs) == 0 {
+		problems = append(problems, fmt.Sprintf("%s has no azs, but other instance groups do", prefix))
+	}
+	if len(instanceGroup.Networks) == 0 {
+		problems = append(problems, fmt.Sprintf("%s has no networks", prefix))
+	}
+	if len(instanceGroup.Jobs) == 0 {
+		problems = append(problems, fmt.Sprintf("%s has no jobs", prefix))
+	}
+
+	jobs := map[string]bool{}
+	for _, job := range instanceGroup.Jobs {
+		if jobs[job.Name] {
+			problems = append(problems, fmt.Sprintf("%s has job %s more than once", prefix, job.Name))
+		}
+		jobs[job.Name] = true
+		if !releases[job.Release] {
+			problems = append(problems, fmt.Sprintf("%s job %s uses undeclared release %q", prefix, job.Name, job.Release))
+		}
+	}
+	return problems
+}
+
+// variableReferences returns the distinct ((references)) in manifest.
+func variableReferences(manifest bosh.BoshManifest) ([]string, error) {
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	for _, match := range variableReferencePattern.FindAllStringSubmatch(string(manifestBytes), -1) {
+		found[strings.TrimPrefix(strings.TrimSpace(match[1]), "!")] = true
+	}
+	var references []string
+	for reference := range found {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+	return references, nil
+}
+
+func variableReferenceProblem(reference string, variables map[string]bool, secrets serviceadapter.ODBManagedSecrets) string {
+	if strings.HasPrefix(reference, "/") {
+		return ""
+	}
+	if strings.HasPrefix(reference, serviceadapter.ODBSecretPrefix+":") {
+		key := strings.TrimPrefix(reference, serviceadapter.ODBSecretPrefix+":")
+		if _, found := secrets[key]; !found {
+			return fmt.Sprintf("((%s)) refers to an ODB managed secret the adapter did not return", reference)
+		}
+		return ""
+	}
+	if name := strings.SplitN(reference, ".", 2)[0]; !variables[name] {
+		return fmt.Sprintf("((%s)) is neither a declared variable nor an ODB managed secret", reference)
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package adapter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("ValidateManifest", func() {
+	var (
+		manifest bosh.BoshManifest
+		secrets  serviceadapter.ODBManagedSecrets
+	)
+
+	BeforeEach(func() {
+		manifest = bosh.BoshManifest{
+			Name:      "some-deployment",
+			Releases:  []bosh.Release{{Name: "redis", Version: "4"}},
+			Stemcells: []bosh.Stemcell{{Alias: "only-stemcell", OS: "ubuntu-jammy", Version: "1.1"}},
+			InstanceGroups: []bosh.InstanceGroup{{
+				Name:      "redis-server",
+				Instances: 1,
+				Stemcell:  "only-stemcell",
+				AZs:       []string{"z1"},
+				Networks:  []bosh.Network{{Name: "some-network"}},
+				Jobs: []bosh.Job{{
+					Name:                      "redis-server",
+					Release:                   "redis",
+					CustomProviderDefinitions: []bosh.CustomProviderDefinition{{Name: "redis-server-link", Type: "address"}},
+					Properties: map[string]interface{}{
+						"redis": map[interface{}]interface{}{
+							"password":           "((secret_pass))",
+							"ca_cert":            "((instance_certificate.ca))",
+							"odb_managed_secret": "((odb_secret:odb_managed_secret))",
+							"secret":             "((/some/absolute/path))",
+						},
+					},
+				}},
+			}},
+			Variables: []bosh.Variable{
+				{Name: "secret_pass", Type: "password"},
+				{
+					Name: "instance_certificate",
+					Type: "certificate",
+					Consumes: &bosh.VariableConsumes{
+						CommonName: bosh.VariableConsumesLink{From: "redis-server-link"},
+					},
+				},
+			},
+		}
+		secrets = serviceadapter.ODBManagedSecrets{"odb_managed_secret": "some-value"}
+	})
+
+	It("accepts a valid manifest", func() {
+		Expect(adapter.ValidateManifest(manifest, secrets)).To(Succeed())
+	})
+
+	DescribeTable("rejects manifests BOSH would reject",
+		func(mutate func(*bosh.BoshManifest), expectedProblem string) {
+			mutate(&manifest)
+			Expect(adapter.ValidateManifest(manifest, secrets)).To(MatchError(ContainSubstring(expectedProblem)))
+		},
+		Entry("duplicate instance groups", func(m *bosh.BoshManifest) {
+			m.InstanceGroups = append(m.InstanceGroups, m.InstanceGroups[0])
+		}, "instance group redis-server is declared more than once"),
+		Entry("duplicate jobs", func(m *bosh.BoshManifest) {
+			m.InstanceGroups[0].Jobs = append(m.InstanceGroups[0].Jobs, bosh.Job{Name: "redis-server", Release: "redis"})
+		}, "instance group redis-server has job redis-server more than once"),
+		Entry("jobs from a missing release", func(m *bosh.BoshManifest) {
+			m.InstanceGroups[0].Jobs[0].Release = "other-release"
+		}, `instance group redis-server job redis-server uses undeclared release "other-release"`),
+		Entry("AZs on only some instance groups", func(m *bosh.BoshManifest) {
+			errand := m.InstanceGroups[0]
+			errand.Name = "health-check"
+			errand.AZs = nil
+			m.InstanceGroups = append(m.InstanceGroups, errand)
+		}, "instance group health-check has no azs, but other instance groups do"),
+		Entry("no networks", func(m *bosh.BoshManifest) {
+			m.InstanceGroups[0].Networks = nil
+		}, "instance group redis-server has no networks"),
+		Entry("an undeclared stemcell", func(m *bosh.BoshManifest) {
+			m.InstanceGroups[0].Stemcell = "other-stemcell"
+		}, `instance group redis-server uses undeclared stemcell "other-stemcell"`),
+		Entry("links no job provides", func(m *bosh.BoshManifest) {
+			m.InstanceGroups[0].Jobs[0].CustomProviderDefinitions = nil
+		}, "variable instance_certificate consumes link redis-server-link, which no job provides"),
+		Entry("undeclared variables", func(m *bosh.BoshManifest) {
+			m.Variables = m.Variables[1:]
+		}, "((secret_pass)) is neither a declared variable nor an ODB managed secret"),
+		Entry("duplicate variables", func(m *bosh.BoshManifest) {
+			m.Variables = append(m.Variables, m.Variables[0])
+		}, "variable secret_pass is declared more than once"),
+	)
+
+	It("accepts instance groups without AZs when none have any", func() {
+		manifest.InstanceGroups[0].AZs = nil
+		Expect(adapter.ValidateManifest(manifest, secrets)).To(Succeed())
+	})
+
+	It("rejects references to ODB managed secrets that are not returned", func() {
+		err := adapter.ValidateManifest(manifest, serviceadapter.ODBManagedSecrets{})
+		Expect(err).To(MatchError("((odb_secret:odb_managed_secret)) refers to an ODB managed secret the adapter did not return"))
+	})
+
+	It("reports every problem at once", func() {
+		manifest.InstanceGroups[0].Networks = nil
+		manifest.Variables = nil
+
+		err := adapter.ValidateManifest(manifest, secrets)
+		Expect(err).To(MatchError(ContainSubstring("has no networks; ")))
+		Expect(err).To(MatchError(ContainSubstring("((instance_certificate.ca)) is neither")))
+		Expect(err).To(MatchError(ContainSubstring("((secret_pass)) is neither")))
+	})
+})
@@ -0,0 +1,278 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Recording is one adapter invocation as the broker made it, with secrets
+// redacted.
+type Recording struct {
+	Timestamp string   `json:"timestamp"`
+	Args      []string `json:"args"`
+	Stdin     string   `json:"stdin,omitempty"`
+	Stdout    string   `json:"stdout"`
+	ExitCode  int      `json:"exit_code"`
+
+	// Path is the file the recording was loaded from.
+	Path string `json:"-"`
+}
+
+// Recorder saves each recording as its own JSON file in Dir.
+type Recorder struct {
+	Dir string
+	Now func() time.Time
+}
+
+func (r Recorder) Save(recording Recording) (string, error) {
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	recordedAt := now().UTC()
+	recording.Timestamp = recordedAt.Format(time.RFC3339Nano)
+
+	args := make([]string, len(recording.Args))
+	for i, arg := range recording.Args {
+		args[i] = RedactRecorded(arg)
+	}
+	recording.Args = args
+	recording.Stdin = RedactRecorded(recording.Stdin)
+	recording.Stdout = RedactRecorded(recording.Stdout)
+
+	data, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	operation := "unknown"
+	if len(recording.Args) > 0 {
+		operation = recording.Args[0]
+	}
+	name := fmt.Sprintf("%s-%s.json", recordedAt.Format("20060102T150405.000000000Z"), operation)
+	path := filepath.Join(r.Dir, name)
+	if err := os.MkdirAll(r.Dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create recording directory %s: %s", r.Dir, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("could not write recording %s: %s", path, err)
+	}
+	return path, nil
+}
+
+// LoadRecordings reads the recordings at paths, which are recording files or
+// directories of them, in the order they were made.
+func LoadRecordings(paths ...string) ([]Recording, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	var recordings []Recording
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var recording Recording
+		if err := json.Unmarshal(data, &recording); err != nil {
+			return nil, fmt.Errorf("could not parse recording %s: %s", file, err)
+		}
+		recording.Path = file
+		recordings = append(recordings, recording)
+	}
+	return recordings, nil
+}
+
+// replayableOperations are the operations that only print their output, and
+// can therefore be replayed without changing service instances or CredHub.
+var replayableOperations = map[string]bool{
+	"generate-manifest":     true,
+	"generate-plan-schemas": true,
+	"dashboard-url":         true,
+}
+
+// Replayable reports whether the recorded operation has no side effects.
+// Bindings, for instance, create users on the instance and credentials in
+// CredHub, so they are never replayed.
+func (r Recording) Replayable() bool {
+	return len(r.Args) > 0 && replayableOperations[r.Args[0]]
+}
+
+// InvocationRunner runs the adapter with args and stdin, returning what it
+// printed to stdout and its exit code.
+type InvocationRunner func(args []string, stdin string) (string, int, error)
+
+// ReplayResult compares a recording to what the adapter does now.
+type ReplayResult struct {
+	ExitCode         int
+	RecordedExitCode int
+	Diff             ManifestDiff
+}
+
+func (r ReplayResult) Matches() bool {
+	return r.ExitCode == r.RecordedExitCode && len(r.Diff) == 0
+}
+
+func (r ReplayResult) String() string {
+	if r.Matches() {
+		return "output unchanged\n"
+	}
+	var text strings.Builder
+	if r.ExitCode != r.RecordedExitCode {
+		fmt.Fprintf(&text, "  exit code: %d -> %d\n", r.RecordedExitCode, r.ExitCode)
+	}
+	r.Diff.writeEntries(&text)
+	return text.String()
+}
+
+// Replay runs the recorded invocation again and compares the output, after
+// redacting it like the recording, value by value. Only recordings of
+// operations without side effects are replayed. Manifests and other YAML
+// or JSON documents embedded in the output are compared by their contents.
+func Replay(recording Recording, run InvocationRunner) (ReplayResult, error) {
+	if !recording.Replayable() {
+		return ReplayResult{}, fmt.Errorf("%s has side effects and cannot be replayed", strings.Join(recording.Args, " "))
+	}
+
+	stdout, exitCode, err := run(recording.Args, recording.Stdin)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	recordedValues := map[string]string{}
+	flattenValue("", expandDocument(recording.Stdout), recordedValues)
+	replayedValues := map[string]string{}
+	flattenValue("", expandDocument(RedactRecorded(stdout)), replayedValues)
+
+	return ReplayResult{
+		ExitCode:         exitCode,
+		RecordedExitCode: recording.ExitCode,
+		Diff:             diffValues(recordedValues, replayedValues),
+	}, nil
+}
+
+// RedactRecorded hides secrets in an argument, stdin or stdout. JSON and YAML
+// documents, including those embedded as strings, have the values of secret
+// looking keys replaced so that the result still parses and can be replayed.
+func RedactRecorded(text string) string {
+	document, isJSON, ok := parseDocument(text)
+	if !ok {
+		return RedactSecrets(text)
+	}
+	document = redactDocument(document)
+
+	if isJSON {
+		data, err := json.Marshal(jsonCompatible(document))
+		if err != nil {
+			return RedactSecrets(text)
+		}
+		return string(data)
+	}
+	data, err := yaml.Marshal(document)
+	if err != nil {
+		return RedactSecrets(text)
+	}
+	return string(data)
+}
+
+func redactDocument(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		redacted := make(map[interface{}]interface{}, len(v))
+		for key, nested := range v {
+			if text, ok := nested.(string); ok && isSecretKey(fmt.Sprint(key)) {
+				redacted[key] = redactDiffValue(text)
+				continue
+			}
+			redacted[key] = redactDocument(nested)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, nested := range v {
+			redacted[i] = redactDocument(nested)
+		}
+		return redacted
+	case string:
+		return RedactRecorded(v)
+	}
+	return value
+}
+
+// expandDocument parses text, and any documents embedded in it, so that they
+// can be flattened and compared.
+func expandDocument(text string) interface{} {
+	document, _, ok := parseDocument(text)
+	if !ok {
+		return text
+	}
+	return expandValue(document)
+}
+
+func expandValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		expanded := make(map[interface{}]interface{}, len(v))
+		for key, nested := range v {
+			expanded[key] = expandValue(nested)
+		}
+		return expanded
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, nested := range v {
+			expanded[i] = expandValue(nested)
+		}
+		return expanded
+	case string:
+		return expandDocument(v)
+	}
+	return value
+}
+
+// parseDocument parses text if it is a JSON or YAML object or list. Plain
+// strings, which YAML also accepts, are not documents.
+func parseDocument(text string) (interface{}, bool, bool) {
+	trimmed := strings.TrimSpace(text)
+	isJSON := strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")
+	if !isJSON && !strings.Contains(trimmed, "\n") && !strings.Contains(trimmed, ": ") {
+		return nil, false, false
+	}
+
+	var document interface{}
+	if err := yaml.Unmarshal([]byte(text), &document); err != nil {
+		return nil, false, false
+	}
+	switch document.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return document, isJSON, true
+	}
+	return nil, false, false
+}
+
+// isSecretKey reports whether a key holds a secret. Keys naming where a secret
+// is stored, like ca_cert_path, do not.
+func isSecretKey(key string) bool {
+	return !strings.HasSuffix(strings.ToLower(key), "_path") && isSecretPath(key)
+}
@@ -0,0 +1,165 @@
+package adapter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+var _ = Describe("Recording", func() {
+	var (
+		dir      string
+		recorder adapter.Recorder
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "recordings")
+		Expect(err).NotTo(HaveOccurred())
+		recorder = adapter.Recorder{
+			Dir: filepath.Join(dir, "nested"),
+			Now: func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) },
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("saves recordings readable only by the adapter user", func() {
+		path, err := recorder.Save(adapter.Recording{
+			Args:   []string{"dashboard-url", "some-instance"},
+			Stdout: `{"dashboard_url":"https://example.com"}`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filepath.Base(path)).To(Equal("20261018T120000.000000000Z-dashboard-url.json"))
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		recordings, err := adapter.LoadRecordings(dir + "/nested")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recordings).To(Equal([]adapter.Recording{{
+			Timestamp: "2026-10-18T12:00:00Z",
+			Args:      []string{"dashboard-url", "some-instance"},
+			Stdout:    `{"dashboard_url":"https://example.com"}`,
+			Path:      path,
+		}}))
+	})
+
+	It("redacts secrets in documents embedded in the input and output", func() {
+		stdin := `{"create_binding":{"request_params":"{\"parameters\":{\"password\":\"hunter2\"}}",` +
+			`"manifest":"properties:\n  redis:\n    password: ((redis_password))\n    secret: plain-secret\n    ca_cert_path: /some/path\n"}}`
+		path, err := recorder.Save(adapter.Recording{
+			Args:   []string{"create-binding"},
+			Stdin:  stdin,
+			Stdout: `{"credentials":{"host":"10.0.0.1","password":"s3cret"}}`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("hunter2"))
+		Expect(string(data)).NotTo(ContainSubstring("plain-secret"))
+		Expect(string(data)).NotTo(ContainSubstring("s3cret"))
+
+		var recording adapter.Recording
+		Expect(json.Unmarshal(data, &recording)).To(Succeed())
+		var input map[string]map[string]string
+		Expect(json.Unmarshal([]byte(recording.Stdin), &input)).To(Succeed())
+		Expect(input["create_binding"]["request_params"]).To(MatchJSON(`{"parameters":{"password":"<redacted>"}}`))
+		Expect(input["create_binding"]["manifest"]).To(MatchYAML(`
+properties:
+  redis:
+    password: ((redis_password))
+    secret: <redacted>
+    ca_cert_path: /some/path
+`))
+		Expect(recording.Stdout).To(MatchJSON(`{"credentials":{"host":"10.0.0.1","password":"<redacted>"}}`))
+	})
+
+	It("loads the recordings in a directory in the order they were made", func() {
+		for i, operation := range []string{"generate-manifest", "create-binding"} {
+			now := time.Date(2026, 10, 18, 12, 0, i, 0, time.UTC)
+			recorder.Now = func() time.Time { return now }
+			_, err := recorder.Save(adapter.Recording{Args: []string{operation}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		recordings, err := adapter.LoadRecordings(recorder.Dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recordings).To(HaveLen(2))
+		Expect(recordings[0].Args).To(Equal([]string{"generate-manifest"}))
+		Expect(recordings[1].Args).To(Equal([]string{"create-binding"}))
+	})
+})
+
+var _ = Describe("Replay", func() {
+	recording := adapter.Recording{
+		Args:   []string{"generate-manifest"},
+		Stdin:  `{"generate_manifest":{}}`,
+		Stdout: `{"manifest":"name: some-deployment\nproperties:\n  maxclients: 100\n  password: <redacted>\n","secrets":{"password":"<redacted>"}}`,
+	}
+
+	runnerPrinting := func(stdout string, exitCode int) adapter.InvocationRunner {
+		return func(args []string, stdin string) (string, int, error) {
+			Expect(args).To(Equal(recording.Args))
+			Expect(stdin).To(Equal(recording.Stdin))
+			return stdout, exitCode, nil
+		}
+	}
+
+	It("matches when only redacted values differ", func() {
+		result, err := adapter.Replay(recording, runnerPrinting(
+			`{"secrets":{"password":"new"},"manifest":"name: some-deployment\nproperties:\n  password: other\n  maxclients: 100\n"}`, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Matches()).To(BeTrue())
+		Expect(result.String()).To(Equal("output unchanged\n"))
+	})
+
+	It("reports changed values in embedded documents", func() {
+		result, err := adapter.Replay(recording, runnerPrinting(
+			`{"secrets":{"password":"new"},"manifest":"name: some-deployment\nproperties:\n  maxclients: 200\n  password: other\n"}`, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Matches()).To(BeFalse())
+		Expect(result.Diff).To(Equal(adapter.ManifestDiff{{
+			Path:   "manifest.properties.maxclients",
+			Change: adapter.ManifestValueChanged,
+			From:   "100",
+			To:     "200",
+		}}))
+	})
+
+	It("reports changed exit codes", func() {
+		result, err := adapter.Replay(recording, runnerPrinting("", 1))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Matches()).To(BeFalse())
+		Expect(result.String()).To(HavePrefix("  exit code: 0 -> 1\n"))
+	})
+
+	It("fails when the adapter cannot be run", func() {
+		_, err := adapter.Replay(recording, func([]string, string) (string, int, error) {
+			return "", 0, errors.New("no such binary")
+		})
+		Expect(err).To(MatchError("no such binary"))
+	})
+
+	It("refuses to replay operations with side effects", func() {
+		binding := adapter.Recording{Args: []string{"create-binding"}, Stdin: `{"create_binding":{}}`}
+		Expect(binding.Replayable()).To(BeFalse())
+
+		_, err := adapter.Replay(binding, func([]string, string) (string, int, error) {
+			Fail("the adapter should not be run")
+			return "", 0, nil
+		})
+		Expect(err).To(MatchError("create-binding has side effects and cannot be replayed"))
+	})
+})
@@ -4,33 +4,76 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
 	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
 	"github.com/pkg/errors"
 )
 
+const (
+	DNSFirstHostPolicy = "dns-first"
+	IPFirstHostPolicy  = "ip-first"
+
+	InlineCredentialsMode     = "inline"
+	CredHubRefCredentialsMode = "credhub-ref"
+
+	DefaultCredHubPathPrefix = "/c/redis-service-adapter"
+)
+
 type Binder struct {
-	StderrLogger *log.Logger
-	Config       Config
+	StderrLogger    *log.Logger
+	Config          Config
+	CredentialStore CredentialStore
+	Bindings        BindingRegistry
+	AuditLog        AuditLog
+	Hooks           Hooks
 }
 
 func (b Binder) CreateBinding(params serviceadapter.CreateBindingParams) (serviceadapter.Binding, error) {
+	b.StderrLogger = WithLogFields(b.StderrLogger, LogFields{
+		Operation:  "create-binding",
+		Deployment: params.Manifest.Name,
+		BindingID:  params.BindingID,
+		Plan:       planID(params.RequestParams),
+	})
+
+	binding, err := b.createBinding(params)
+	logAdapterError(b.StderrLogger, err)
+	b.recordAudit(AuditRecord{
+		Operation:  "create-binding",
+		Deployment: params.Manifest.Name,
+		BindingID:  params.BindingID,
+		Plan:       planID(params.RequestParams),
+		Parameters: redactParameters(params.RequestParams.ArbitraryParams()),
+	}, err)
+	return binding, err
+}
+
+func (b Binder) createBinding(params serviceadapter.CreateBindingParams) (serviceadapter.Binding, error) {
 	if params.RequestParams.BindResource().BackupAgent {
 		return serviceadapter.Binding{
 			BackupAgentURL: "http://www.example.com/backup-agent-url",
 		}, nil
 	}
 
+	// Only the bound app is given access to credentials stored in CredHub
+	appGUID := params.RequestParams.BindResource().AppGuid
+	if b.Config.BindingCredentialsMode == CredHubRefCredentialsMode && appGUID == "" {
+		return serviceadapter.Binding{}, serviceadapter.NewAppGuidNotProvidedError(
+			errors.New("bindings in credhub-ref credentials mode must be for an app"),
+		)
+	}
+
 	ctx := params.RequestParams.ArbitraryContext()
 	platform := params.RequestParams.Platform()
 	if len(ctx) == 0 || platform == "" || platform != "cloudfoundry" {
 		b.StderrLogger.Println("Non Cloud Foundry platform (or pre OSBAPI 2.13) detected")
 	}
-	redisHost, err := getRedisHost(params.DeploymentTopology)
+	redisHosts, err := b.redisHosts(params.DeploymentTopology, params.DNSAddresses)
 	if err != nil {
-		b.StderrLogger.Println(err.Error())
-		return serviceadapter.Binding{}, errors.New("")
+		return serviceadapter.Binding{}, NewOperatorError(err)
 	}
 
 	resolvedSecrets := make(map[string]string, len(params.Secrets))
@@ -52,53 +95,295 @@ func (b Binder) CreateBinding(params serviceadapter.CreateBindingParams) (servic
 			path, ok := redisPlanProperties(params.Manifest)[manifestSecret].(string) // ((/odb/....))
 			if !ok || path == "" {
 				err := fmt.Errorf("could not find path for %s", manifestSecret)
-				b.StderrLogger.Println(err.Error())
 				if field.Optional {
+					b.StderrLogger.Println(err.Error())
 					continue
 				}
-				return serviceadapter.Binding{}, err
+				return serviceadapter.Binding{}, NewOperatorError(err)
 			}
 
 			matchResult, err := regexp.MatchString(`\(\([^()]+\)\)`, path)
 			if err != nil {
-				b.StderrLogger.Println(err.Error())
-				return serviceadapter.Binding{}, err
+				return serviceadapter.Binding{}, NewInternalError(err)
 			}
 
 			if !matchResult {
 				err := fmt.Errorf("expecting a credhub ref string with format ((xxx)), but got: %s", path)
-				b.StderrLogger.Println(err.Error())
-				return serviceadapter.Binding{}, err
+				return serviceadapter.Binding{}, NewOperatorError(err)
 			}
 
 			value, ok := params.Secrets[path]
 			if !ok || value == "" {
 				err := errors.New("manifest wasn't correctly interpolated: missing value for `" + path + "`")
-				b.StderrLogger.Println(err.Error())
-				return serviceadapter.Binding{}, err
+				return serviceadapter.Binding{}, NewOperatorError(err)
 			}
 			resolvedSecrets[field.Name] = value
 		}
 	}
 
+	adminPassword, ok := redisPlanProperties(params.Manifest)["password"].(string)
+	if !ok {
+		return serviceadapter.Binding{}, NewOperatorError(errors.New("could not find the redis password in the manifest"))
+	}
+
+	instance := RedisInstance{
+		Hosts:          redisHosts,
+		Port:           RedisServerPort,
+		TLSPort:        tlsPortForRedisServer(redisPlanProperties(params.Manifest)),
+		AdminPassword:  adminPassword,
+		CACert:         resolvedSecrets["ca_cert"],
+		Nodes:          params.DeploymentTopology[b.redisInstanceGroupName()],
+		DeniedCommands: deniedCommandsForRedisServer(redisPlanProperties(params.Manifest)),
+	}
+
+	connection := redisConnection{
+		Host:     redisHosts[0],
+		Hosts:    redisHosts,
+		Port:     instance.Port,
+		TLSPort:  instance.TLSPort,
+		Password: instance.AdminPassword,
+		Modules:  modulesForRedisServer(redisPlanProperties(params.Manifest)),
+	}
+
+	if b.Bindings != nil {
+		exists, err := b.Bindings.BindingExists(instance, params.BindingID)
+		if err != nil {
+			return serviceadapter.Binding{}, NewTransientError(err)
+		}
+		if exists {
+			return serviceadapter.Binding{}, serviceadapter.NewBindingAlreadyExistsError(
+				fmt.Errorf("binding %s already exists on %s", params.BindingID, params.Manifest.Name),
+			)
+		}
+
+		connection.Username = BindingUsername(params.BindingID)
+		connection.Password, err = CurrentPasswordGenerator()
+		if err != nil {
+			return serviceadapter.Binding{}, NewInternalError(err)
+		}
+	}
+	password := connection.Password
+
+	profile := b.Config.BindingCredentialsProfile
+	requestedProfile, profileRequested := params.RequestParams.ArbitraryParams()[CredentialsProfileParameter]
+	if profileRequested {
+		var ok bool
+		profile, ok = requestedProfile.(string)
+		if !ok {
+			return serviceadapter.Binding{}, NewUserError(fmt.Errorf("%s must be a string", CredentialsProfileParameter))
+		}
+	}
+
+	defaultCredentials := map[string]interface{}{
+		"host":               redisHosts[0],
+		"hosts":              redisHosts,
+		"port":               RedisServerPort,
+		"generated_secret":   resolvedSecrets[GeneratedSecretKey],
+		"password":           password,
+		"secret":             resolvedSecrets["secret"],
+		"odb_managed_secret": resolvedSecrets[ManagedSecretKey],
+		"dns_addresses":      params.DNSAddresses,
+	}
+	if b.Config.DebugBindingCredentials {
+		defaultCredentials["passed_in_secrets"] = params.Secrets
+		defaultCredentials["expected_resolved_secrets"] = resolvedSecrets
+	}
+
+	if connection.Username != "" {
+		defaultCredentials["username"] = connection.Username
+	}
+
+	credentials, err := credentialsForProfile(profile, connection, defaultCredentials)
+	if err != nil {
+		// only a profile the user asked for is theirs to fix
+		if profileRequested {
+			return serviceadapter.Binding{}, NewUserError(err)
+		}
+		return serviceadapter.Binding{}, NewOperatorError(err)
+	}
+
+	if b.Bindings != nil {
+		if err := b.Bindings.CreateBinding(instance, params.BindingID, connection.Password); err != nil {
+			return serviceadapter.Binding{}, NewTransientError(err)
+		}
+	}
+
+	if b.Config.VerifyBindings {
+		if err := b.verifyBinding(instance, connection); err != nil {
+			b.removeBinding(instance, params.BindingID)
+			return serviceadapter.Binding{}, classifyVerificationError(err)
+		}
+	}
+
+	if err := b.Hooks.decorateCredentials(params, credentials); err != nil {
+		b.removeBinding(instance, params.BindingID)
+		return serviceadapter.Binding{}, err
+	}
+
+	switch b.Config.BindingCredentialsMode {
+	case "", InlineCredentialsMode:
+	case CredHubRefCredentialsMode:
+		credentials, err = b.storeCredentials(params.BindingID, appGUID, credentials)
+		if err != nil {
+			b.removeBinding(instance, params.BindingID)
+			return serviceadapter.Binding{}, err
+		}
+	default:
+		err := fmt.Errorf("unknown binding credentials mode %q", b.Config.BindingCredentialsMode)
+		b.removeBinding(instance, params.BindingID)
+		return serviceadapter.Binding{}, NewOperatorError(err)
+	}
+
 	return serviceadapter.Binding{
-		Credentials: map[string]interface{}{
-			"host":                      redisHost,
-			"port":                      RedisServerPort,
-			"generated_secret":          resolvedSecrets[GeneratedSecretKey],
-			"password":                  redisPlanProperties(params.Manifest)["password"].(string),
-			"secret":                    resolvedSecrets["secret"],
-			"odb_managed_secret":        resolvedSecrets[ManagedSecretKey],
-			"dns_addresses":             params.DNSAddresses,
-			"passed_in_secrets":         params.Secrets,
-			"expected_resolved_secrets": resolvedSecrets,
-		},
+		Credentials: credentials,
 	}, nil
 }
 
+// storeCredentials puts the credentials in the credential store, lets the app
+// read them, and returns a credhub-ref pointing at them, so the secrets never
+// go through the Cloud Controller.
+func (b Binder) storeCredentials(bindingID, appGUID string, credentials map[string]interface{}) (map[string]interface{}, error) {
+	if b.CredentialStore == nil {
+		return nil, NewOperatorError(errors.New("binding credentials mode is credhub-ref but no credential store is configured"))
+	}
+
+	path := b.credentialsPath(bindingID)
+	if err := b.CredentialStore.SetCredentials(path, credentials); err != nil {
+		return nil, NewTransientError(err)
+	}
+	if err := b.CredentialStore.GrantReadAccess(path, appGUID); err != nil {
+		if deleteErr := b.CredentialStore.DeleteCredentials(path); deleteErr != nil {
+			b.StderrLogger.Printf("could not clean up credentials of binding %s: %s", bindingID, deleteErr)
+		}
+		return nil, NewTransientError(err)
+	}
+	return map[string]interface{}{"credhub-ref": path}, nil
+}
+
+// removeBinding cleans up a binding that was created before the bind failed, so
+// it is not left behind as an orphan.
+func (b Binder) removeBinding(instance RedisInstance, bindingID string) {
+	if b.Bindings == nil {
+		return
+	}
+	if err := b.Bindings.DeleteBinding(instance, bindingID); err != nil {
+		b.StderrLogger.Printf("could not clean up binding %s: %s", bindingID, err)
+	}
+}
+
+func (b Binder) credentialsPath(bindingID string) string {
+	prefix := b.Config.CredHub.PathPrefix
+	if prefix == "" {
+		prefix = DefaultCredHubPathPrefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + bindingID + "/credentials"
+}
+
 func (b Binder) DeleteBinding(params serviceadapter.DeleteBindingParams) error {
-	b.StderrLogger.Printf("DNS addresses: %#v", params.DNSAddresses)
+	b.StderrLogger = WithLogFields(b.StderrLogger, LogFields{
+		Operation:  "delete-binding",
+		Deployment: params.Manifest.Name,
+		BindingID:  params.BindingID,
+		Plan:       planID(params.RequestParams),
+	})
+	debugf(b.StderrLogger, "DNS addresses: %#v", params.DNSAddresses)
+
+	err := b.deleteBinding(params)
+	logAdapterError(b.StderrLogger, err)
+	b.recordAudit(AuditRecord{
+		Operation:  "delete-binding",
+		Deployment: params.Manifest.Name,
+		BindingID:  params.BindingID,
+		Plan:       planID(params.RequestParams),
+	}, err)
+	return err
+}
+
+func (b Binder) deleteBinding(params serviceadapter.DeleteBindingParams) error {
+	if err := b.verifyDeleteBindingSecrets(params); err != nil {
+		return NewOperatorError(err)
+	}
+
+	// a binding missing from the registry may still have credentials left
+	// behind by an earlier delete that failed half way, so they are deleted
+	// before reporting it as not found
+	var registryErr error
+	if b.Bindings != nil {
+		registryErr = b.deleteRegisteredBinding(params)
+		if _, notFound := registryErr.(serviceadapter.BindingNotFoundError); registryErr != nil && !notFound {
+			return registryErr
+		}
+	}
+
+	if b.Config.BindingCredentialsMode == CredHubRefCredentialsMode {
+		if b.CredentialStore == nil {
+			return NewOperatorError(errors.New("binding credentials mode is credhub-ref but no credential store is configured"))
+		}
+		if err := b.CredentialStore.DeleteCredentials(b.credentialsPath(params.BindingID)); err != nil {
+			return NewTransientError(err)
+		}
+	}
+	return registryErr
+}
+
+func (b Binder) recordAudit(record AuditRecord, err error) {
+	if b.AuditLog == nil {
+		return
+	}
+	if auditErr := b.AuditLog.Record(auditOutcome(record, err)); auditErr != nil {
+		b.StderrLogger.Println(auditErr.Error())
+	}
+}
+
+func (b Binder) deleteBindingInstance(params serviceadapter.DeleteBindingParams) (RedisInstance, error) {
+	redisHosts, err := b.redisHosts(params.DeploymentTopology, params.DNSAddresses)
+	if err != nil {
+		return RedisInstance{}, NewOperatorError(err)
+	}
+
+	redisProperties := redisPlanProperties(params.Manifest)
+	adminPassword, _ := redisProperties["password"].(string)
+	caCert := ""
+	if caCertPath, ok := redisProperties["ca_cert"].(string); ok {
+		caCert = params.Secrets[caCertPath]
+	}
+	return RedisInstance{
+		Hosts:          redisHosts,
+		Port:           RedisServerPort,
+		TLSPort:        tlsPortForRedisServer(redisProperties),
+		AdminPassword:  adminPassword,
+		CACert:         caCert,
+		Nodes:          params.DeploymentTopology[b.redisInstanceGroupName()],
+		DeniedCommands: deniedCommandsForRedisServer(redisProperties),
+	}, nil
+}
+
+func (b Binder) deleteRegisteredBinding(params serviceadapter.DeleteBindingParams) error {
+	instance, err := b.deleteBindingInstance(params)
+	if err != nil {
+		return err
+	}
+
+	exists, err := b.Bindings.BindingExists(instance, params.BindingID)
+	if err != nil {
+		return NewTransientError(err)
+	}
+	if !exists {
+		return serviceadapter.NewBindingNotFoundError(
+			fmt.Errorf("binding %s does not exist on %s", params.BindingID, params.Manifest.Name),
+		)
+	}
+
+	if err := b.Bindings.DeleteBinding(instance, params.BindingID); err != nil {
+		return NewTransientError(err)
+	}
+	return nil
+}
 
+// verifyDeleteBindingSecrets checks the broker resolved the manifest's secrets
+// and that they let the adapter log in to the instance, so a delete never acts
+// on an instance it cannot authenticate against.
+func (b Binder) verifyDeleteBindingSecrets(params serviceadapter.DeleteBindingParams) error {
 	if !b.Config.SecureManifestsEnabled {
 		if len(params.Secrets) != 0 {
 			return errors.New("DeleteBinding received secrets when secure manifests are disabled")
@@ -106,31 +391,68 @@ func (b Binder) DeleteBinding(params serviceadapter.DeleteBindingParams) error {
 		return nil
 	}
 
-	actualSecretValue, ok := params.Secrets["(("+GeneratedSecretVariableName+"))"]
-	if !ok {
+	if params.Secrets["(("+GeneratedSecretVariableName+"))"] == "" {
 		return errors.New("The required secret was not provided to DeleteBinding")
 	}
 
-	secretIsValid := simulatedLoginToRedisSucceeds(actualSecretValue)
-	if !secretIsValid {
-		return errors.New("The incorrect secret value was provided to DeleteBinding")
+	instance, err := b.deleteBindingInstance(params)
+	if err != nil {
+		return err
+	}
+	if err := b.verifyAdminLogin(instance); err != nil {
+		return classifyVerificationError(err)
 	}
 	return nil
 }
 
-func simulatedLoginToRedisSucceeds(password string) bool {
-	return len(password) > 0
+func (b Binder) redisInstanceGroupName() string {
+	if b.Config.RedisInstanceGroupName != "" {
+		return b.Config.RedisInstanceGroupName
+	}
+	return RedisJobName
 }
 
-func getRedisHost(deploymentTopology bosh.BoshVMs) (string, error) {
-	if len(deploymentTopology) != 1 {
-		return "", fmt.Errorf("expected 1 instance group in the Redis deployment, got %d", len(deploymentTopology))
+// redisHosts returns every address the Redis instances can be reached on,
+// ordered by the configured binding host policy so that the preferred host
+// comes first. Instance groups other than the Redis one, such as errands, are
+// ignored.
+func (b Binder) redisHosts(deploymentTopology bosh.BoshVMs, dnsAddresses serviceadapter.DNSAddresses) ([]string, error) {
+	instanceGroupName := b.redisInstanceGroupName()
+	redisServerIPs, found := deploymentTopology[instanceGroupName]
+	if !found {
+		return nil, fmt.Errorf("no %s instance group found in the Redis deployment", instanceGroupName)
+	}
+	if len(redisServerIPs) == 0 {
+		return nil, fmt.Errorf("expected %s instance group to have at least 1 instance, got 0", instanceGroupName)
 	}
 
-	redisServerIPs := deploymentTopology["redis-server"]
-	if len(redisServerIPs) != 1 {
-		return "", fmt.Errorf("expected redis-server instance group to have only 1 instance, got %d", len(redisServerIPs))
+	var dnsNames []string
+	var dnsKeys []string
+	for key := range dnsAddresses {
+		dnsKeys = append(dnsKeys, key)
 	}
-	return redisServerIPs[0], nil
+	sort.Strings(dnsKeys)
+	for _, key := range dnsKeys {
+		if address := dnsAddresses[key]; address != "" && !containsString(dnsNames, address) {
+			dnsNames = append(dnsNames, address)
+		}
+	}
+
+	switch b.Config.BindingHostPolicy {
+	case "", DNSFirstHostPolicy:
+		return append(dnsNames, redisServerIPs...), nil
+	case IPFirstHostPolicy:
+		return append(append([]string{}, redisServerIPs...), dnsNames...), nil
+	default:
+		return nil, fmt.Errorf("unknown binding host policy %q", b.Config.BindingHostPolicy)
+	}
+}
 
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
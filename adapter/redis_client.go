@@ -0,0 +1,37 @@
+package adapter
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp"
+)
+
+// connectToInstance connects to the first reachable host of the instance,
+// over TLS when the instance has it enabled.
+func connectToInstance(instance RedisInstance, username, password string, timeout time.Duration, retries int) (*resp.Client, error) {
+	port, useTLS := instance.Port, false
+	if instance.TLSPort != 0 {
+		port, useTLS = instance.TLSPort, true
+	}
+
+	var err error
+	for _, host := range instance.Hosts {
+		var client *resp.Client
+		client, err = resp.Dial(resp.Options{
+			Address:     net.JoinHostPort(host, strconv.Itoa(port)),
+			Username:    username,
+			Password:    password,
+			TLS:         useTLS,
+			CACert:      instance.CACert,
+			DialTimeout: timeout,
+			IOTimeout:   timeout,
+			Retries:     retries,
+		})
+		if err == nil {
+			return client, nil
+		}
+	}
+	return nil, err
+}
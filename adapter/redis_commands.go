@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const (
+	// DisabledCommandsPlanProperty lists the commands nobody may run on the
+	// plan's instances, e.g. [FLUSHALL, FLUSHDB, CONFIG, DEBUG, MONITOR].
+	DisabledCommandsPlanProperty = "disabled_commands"
+
+	// RenamedCommandsPlanProperty maps commands to the names only operators
+	// know them by, e.g. {KEYS: ops-keys-3f9a}.
+	RenamedCommandsPlanProperty = "renamed_commands"
+)
+
+// aclManagementCommands are the commands the adapter runs as the default user
+// to manage ACL binding users.
+var aclManagementCommands = []string{"ACL", "AUTH", "HELLO"}
+
+var commandNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// CommandRestrictions are the commands a plan disables or renames.
+//
+// Without ACL bindings they are rendered as rename-command directives, which
+// apply to every client. With ACL bindings binding users are denied both the
+// disabled and the renamed commands instead, since ACL rules cannot refer to
+// renamed commands, and the default user, which the adapter and operators
+// connect as, is denied the disabled commands through the redis-server job's
+// default_user_denied_commands property.
+type CommandRestrictions struct {
+	Disabled []string
+	Renamed  map[string]string
+}
+
+// CommandRestrictionsForPlan reads the plan's disabled_commands and
+// renamed_commands, or describes every problem with them.
+func CommandRestrictionsForPlan(planProperties serviceadapter.Properties) (CommandRestrictions, error) {
+	var restrictions CommandRestrictions
+	var problems []string
+
+	if disabled, found := planProperties[DisabledCommandsPlanProperty]; found {
+		if err := decodePlanProperty(disabled, &restrictions.Disabled); err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be a list of commands", DisabledCommandsPlanProperty))
+		}
+	}
+	if renamed, found := planProperties[RenamedCommandsPlanProperty]; found {
+		if err := decodePlanProperty(renamed, &restrictions.Renamed); err != nil {
+			problems = append(problems, fmt.Sprintf("%s must map commands to new names", RenamedCommandsPlanProperty))
+		}
+	}
+
+	for i, command := range restrictions.Disabled {
+		if !commandNameRegexp.MatchString(command) {
+			problems = append(problems, fmt.Sprintf("%s has invalid command %q", DisabledCommandsPlanProperty, command))
+		}
+		restrictions.Disabled[i] = strings.ToUpper(command)
+	}
+
+	renamed := make(map[string]string, len(restrictions.Renamed))
+	newNames := map[string]bool{}
+	for _, command := range sortedCommandNames(restrictions.Renamed) {
+		newName := restrictions.Renamed[command]
+		if !commandNameRegexp.MatchString(command) {
+			problems = append(problems, fmt.Sprintf("%s has invalid command %q", RenamedCommandsPlanProperty, command))
+		}
+		if !commandNameRegexp.MatchString(newName) {
+			problems = append(problems, fmt.Sprintf("%s renames %s to invalid name %q", RenamedCommandsPlanProperty, command, newName))
+		}
+		if newNames[strings.ToUpper(newName)] {
+			problems = append(problems, fmt.Sprintf("%s renames more than one command to %s", RenamedCommandsPlanProperty, newName))
+		}
+		newNames[strings.ToUpper(newName)] = true
+		command = strings.ToUpper(command)
+		if containsString(restrictions.Disabled, command) {
+			problems = append(problems, fmt.Sprintf("%s is both disabled and renamed", command))
+		}
+		renamed[command] = newName
+	}
+	if len(restrictions.Renamed) > 0 {
+		restrictions.Renamed = renamed
+	}
+
+	if len(problems) > 0 {
+		return CommandRestrictions{}, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return restrictions, nil
+}
+
+// renameCommands are the rename-command directives of the restrictions. An
+// empty new name disables the command.
+func (c CommandRestrictions) renameCommands() map[interface{}]interface{} {
+	if len(c.Disabled) == 0 && len(c.Renamed) == 0 {
+		return nil
+	}
+	renames := map[interface{}]interface{}{}
+	for _, command := range c.Disabled {
+		renames[command] = ""
+	}
+	for command, newName := range c.Renamed {
+		renames[command] = newName
+	}
+	return renames
+}
+
+// deniedCommands are the commands binding users are denied when bindings are
+// ACL users.
+func (c CommandRestrictions) deniedCommands() []string {
+	denied := append([]string{}, c.Disabled...)
+	for command := range c.Renamed {
+		denied = append(denied, command)
+	}
+	sort.Strings(denied)
+	return denied
+}
+
+// defaultUserDeniedCommands are the commands the default user is denied when
+// bindings are ACL users. Operators keep the renamed commands under their
+// original names.
+func (c CommandRestrictions) defaultUserDeniedCommands() []string {
+	denied := append([]string{}, c.Disabled...)
+	sort.Strings(denied)
+	return denied
+}
+
+// checkACLBindings rejects restrictions that would stop the adapter managing
+// binding users as the default user.
+func (c CommandRestrictions) checkACLBindings() error {
+	var problems []string
+	for _, command := range aclManagementCommands {
+		if containsString(c.Disabled, command) {
+			problems = append(problems, fmt.Sprintf("%s cannot disable %s, the adapter needs it to manage ACL binding users", DisabledCommandsPlanProperty, command))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// deniedCommandsForRedisServer reads back the denied_commands of a manifest.
+func deniedCommandsForRedisServer(manifestProperties map[interface{}]interface{}) []string {
+	return stringList(manifestProperties["denied_commands"])
+}
+
+// stringList reads back a list of strings from a manifest, which holds
+// []interface{} once it has been through YAML.
+func stringList(value interface{}) []string {
+	var list []string
+	switch values := value.(type) {
+	case []string:
+		list = values
+	case []interface{}:
+		for _, item := range values {
+			list = append(list, fmt.Sprint(item))
+		}
+	}
+	return list
+}
+
+func decodePlanProperty(value interface{}, target interface{}) error {
+	valueJSON, err := json.Marshal(jsonCompatible(value))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(valueJSON, target)
+}
+
+func sortedCommandNames(commands map[string]string) []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
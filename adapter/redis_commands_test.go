@@ -0,0 +1,124 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Command restrictions", func() {
+	var (
+		generator adapter.ManifestGenerator
+		params    serviceadapter.GenerateManifestParams
+	)
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{
+			Config:       config,
+			StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0),
+		}
+
+		var err error
+		params, err = adapter.LoadRenderInputs(adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		params.Plan.Properties[adapter.DisabledCommandsPlanProperty] = []interface{}{"flushall", "CONFIG"}
+		params.Plan.Properties[adapter.RenamedCommandsPlanProperty] = map[string]interface{}{"KEYS": "ops-keys"}
+	})
+
+	redisProperties := func(manifest bosh.BoshManifest) map[interface{}]interface{} {
+		return manifest.InstanceGroups[0].Jobs[0].Properties["redis"].(map[interface{}]interface{})
+	}
+
+	It("renders rename-command directives disabling and renaming the commands", func() {
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("rename_commands", map[interface{}]interface{}{
+			"FLUSHALL": "",
+			"CONFIG":   "",
+			"KEYS":     "ops-keys",
+		}))
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("denied_commands"))
+	})
+
+	It("denies binding users the commands instead when bindings are ACL users", func() {
+		generator.Config.ACLBindingsEnabled = true
+
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("denied_commands", []string{"CONFIG", "FLUSHALL", "KEYS"}))
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("default_user_denied_commands", []string{"CONFIG", "FLUSHALL"}))
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("rename_commands"))
+	})
+
+	It("saves binding users to an ACL file when bindings are ACL users", func() {
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("aclfile"))
+
+		generator.Config.ACLBindingsEnabled = true
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("aclfile", adapter.RedisServerACLFile))
+	})
+
+	It("tells operators when existing binding users will be denied changed commands", func() {
+		generator.Config.ACLBindingsEnabled = true
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		stderr := gbytes.NewBuffer()
+		generator.StderrLogger = log.New(stderr, "", 0)
+		params.PreviousManifest = &output.Manifest
+		params.Plan.Properties[adapter.DisabledCommandsPlanProperty] = []interface{}{"FLUSHALL"}
+
+		_, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stderr).To(gbytes.Say(`binding users are now denied \[FLUSHALL KEYS\] instead of \[CONFIG FLUSHALL KEYS\]; existing binding users are updated on the next bind or unbind`))
+	})
+
+	It("does not let plans disable the commands ACL bindings are managed with", func() {
+		generator.Config.ACLBindingsEnabled = true
+		params.Plan.Properties[adapter.DisabledCommandsPlanProperty] = []interface{}{"acl", "CONFIG"}
+
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind,
+			"disabled_commands cannot disable ACL, the adapter needs it to manage ACL binding users"))
+	})
+
+	It("restricts nothing when the plan does not ask to", func() {
+		delete(params.Plan.Properties, adapter.DisabledCommandsPlanProperty)
+		delete(params.Plan.Properties, adapter.RenamedCommandsPlanProperty)
+
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("rename_commands"))
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("denied_commands"))
+	})
+
+	It("reports every problem with the plan's commands to operators", func() {
+		params.Plan.Properties[adapter.DisabledCommandsPlanProperty] = []interface{}{"CONFIG", "FLUSH ALL"}
+		params.Plan.Properties[adapter.RenamedCommandsPlanProperty] = map[string]interface{}{
+			"config": "ops-config",
+			"DEBUG":  "ops-config",
+			"KEYS":   "",
+		}
+
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind,
+			`disabled_commands has invalid command "FLUSH ALL"; `+
+				`renamed_commands renames KEYS to invalid name ""; `+
+				"renamed_commands renames more than one command to ops-config; "+
+				"CONFIG is both disabled and renamed"))
+	})
+})
@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const (
+	// RedisConfigDirectivesPlanProperty declares the redis.conf directives
+	// users of a plan may set, e.g.
+	//
+	//	redis_config_directives:
+	//	  maxmemory-policy:
+	//	    type: string
+	//	    values: [noeviction, allkeys-lru, volatile-lru]
+	//	    default: noeviction
+	//	  timeout:
+	//	    type: integer
+	//	    min: 0
+	//	    max: 3600
+	RedisConfigDirectivesPlanProperty = "redis_config_directives"
+
+	// RedisConfigParameter is the arbitrary parameter users set directives
+	// with, e.g. {"redis_config": {"timeout": 300}}.
+	RedisConfigParameter = "redis_config"
+
+	IntegerDirectiveType = "integer"
+	StringDirectiveType  = "string"
+	BooleanDirectiveType = "boolean"
+)
+
+// adapterManagedDirectives are set by the adapter or the release, so plans
+// cannot let users change them.
+var adapterManagedDirectives = []string{
+	"bind", "port", "tls-port", "requirepass", "masterauth", "maxclients",
+	"dir", "dbfilename", "include", "loadmodule", "rename-command",
+	"tls-cert-file", "tls-key-file", "tls-ca-cert-file", "aclfile", "unixsocket",
+}
+
+var directiveNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// RedisConfigDirective declares one redis.conf directive users may set.
+// Values restricts strings to a list; Min and Max bound integers.
+type RedisConfigDirective struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Values      []string    `json:"values,omitempty"`
+	Min         *int64      `json:"min,omitempty"`
+	Max         *int64      `json:"max,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// RedisConfigDirectives returns the directives the plan declares, keyed by
+// name, or an error describing every problem with the declaration.
+func RedisConfigDirectives(planProperties serviceadapter.Properties) (map[string]RedisConfigDirective, error) {
+	declaration, found := planProperties[RedisConfigDirectivesPlanProperty]
+	if !found || declaration == nil {
+		return nil, nil
+	}
+
+	var directives map[string]RedisConfigDirective
+	if err := decodePlanProperty(declaration, &directives); err != nil {
+		return nil, fmt.Errorf("invalid plan property %s: %s", RedisConfigDirectivesPlanProperty, err)
+	}
+
+	var problems []string
+	for _, name := range sortedDirectiveNames(directives) {
+		for _, problem := range directives[name].problems(name) {
+			problems = append(problems, fmt.Sprintf("directive %s %s", name, problem))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid plan property %s: %s", RedisConfigDirectivesPlanProperty, strings.Join(problems, "; "))
+	}
+	return directives, nil
+}
+
+func (d RedisConfigDirective) problems(name string) []string {
+	var problems []string
+	if !directiveNameRegexp.MatchString(name) {
+		problems = append(problems, "is not a redis.conf directive name")
+	}
+	if containsString(adapterManagedDirectives, name) {
+		problems = append(problems, "is managed by the adapter")
+	}
+	problems = append(problems, checkOneOf("type", d.Type, IntegerDirectiveType, StringDirectiveType, BooleanDirectiveType)...)
+	if len(d.Values) > 0 && d.Type != StringDirectiveType {
+		problems = append(problems, "values can only restrict string directives")
+	}
+	if (d.Min != nil || d.Max != nil) && d.Type != IntegerDirectiveType {
+		problems = append(problems, "min and max can only bound integer directives")
+	}
+	if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+		problems = append(problems, fmt.Sprintf("min %d is greater than max %d", *d.Min, *d.Max))
+	}
+	if d.Default != nil {
+		if _, err := d.normalize(d.Default); err != nil {
+			problems = append(problems, fmt.Sprintf("default %s", err))
+		}
+	}
+	return problems
+}
+
+// normalize checks value against the declaration and converts it to the type
+// written to the manifest: int64, bool or string. Integers are only bounded by
+// the declared min and max, as directives such as maxmemory take byte counts.
+func (d RedisConfigDirective) normalize(value interface{}) (interface{}, error) {
+	switch d.Type {
+	case IntegerDirectiveType:
+		var number int64
+		switch v := value.(type) {
+		case int:
+			number = int64(v)
+		case int64:
+			number = v
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("must be an integer, got %v", v)
+			}
+			number = int64(v)
+		case float64:
+			// 2^63 is the first float64 beyond the int64 range
+			if v != math.Trunc(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+				return nil, fmt.Errorf("must be an integer, got %v", v)
+			}
+			number = int64(v)
+		default:
+			return nil, fmt.Errorf("must be an integer, got %v", value)
+		}
+		if d.Min != nil && number < *d.Min {
+			return nil, fmt.Errorf("must be at least %d, got %d", *d.Min, number)
+		}
+		if d.Max != nil && number > *d.Max {
+			return nil, fmt.Errorf("must be at most %d, got %d", *d.Max, number)
+		}
+		return number, nil
+	case BooleanDirectiveType:
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be true or false, got %v", value)
+		}
+		return boolean, nil
+	case StringDirectiveType:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string, got %v", value)
+		}
+		if strings.ContainsAny(text, "\r\n") {
+			return nil, fmt.Errorf("must be a single line")
+		}
+		if len(d.Values) > 0 && !containsString(d.Values, text) {
+			return nil, fmt.Errorf("must be one of %s, got %q", strings.Join(d.Values, ", "), text)
+		}
+		return text, nil
+	}
+	return nil, fmt.Errorf("has unknown type %q", d.Type)
+}
+
+// redisConfigForRedisServer works out the directives of the Redis server from
+// those the user requested, then those of the previous manifest, then the
+// declared defaults. Directives the plan no longer declares are dropped.
+func redisConfigForRedisServer(planProperties serviceadapter.Properties, arbitraryParams map[string]interface{}, previousManifestProperties map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	directives, err := RedisConfigDirectives(planProperties)
+	if err != nil {
+		return nil, NewOperatorError(err)
+	}
+
+	requested := map[string]interface{}{}
+	if requestedParam, found := arbitraryParams[RedisConfigParameter]; found {
+		var ok bool
+		requested, ok = requestedParam.(map[string]interface{})
+		if !ok {
+			return nil, NewUserError(fmt.Errorf("%s must be an object of redis.conf directives", RedisConfigParameter))
+		}
+	}
+
+	var problems []string
+	for _, name := range sortedKeys(requested) {
+		directive, declared := directives[name]
+		if !declared {
+			problems = append(problems, fmt.Sprintf("directive %s cannot be set for this service plan", name))
+			continue
+		}
+		if _, err := directive.normalize(requested[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("directive %s %s", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, NewUserError(fmt.Errorf("invalid %s: %s", RedisConfigParameter, strings.Join(problems, "; ")))
+	}
+
+	if len(directives) == 0 {
+		return nil, nil
+	}
+
+	previous, _ := previousManifestProperties["config"].(map[interface{}]interface{})
+	config := map[interface{}]interface{}{}
+	for name, directive := range directives {
+		if value, found := requested[name]; found {
+			config[name], _ = directive.normalize(value)
+		} else if value, err := directive.normalize(previous[name]); previous[name] != nil && err == nil {
+			config[name] = value
+		} else if directive.Default != nil {
+			config[name], _ = directive.normalize(directive.Default)
+		}
+	}
+	return config, nil
+}
+
+func sortedDirectiveNames(directives map[string]RedisConfigDirective) []string {
+	var names []string
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Redis config directives", func() {
+	var (
+		generator adapter.ManifestGenerator
+		params    serviceadapter.GenerateManifestParams
+	)
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{
+			Config:       config,
+			StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0),
+		}
+
+		var err error
+		params, err = adapter.LoadRenderInputs(adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		params.Plan.Properties[adapter.RedisConfigDirectivesPlanProperty] = map[string]interface{}{
+			"maxmemory-policy": map[string]interface{}{
+				"type":    "string",
+				"values":  []interface{}{"noeviction", "allkeys-lru"},
+				"default": "noeviction",
+			},
+			"timeout": map[string]interface{}{
+				"type": "integer",
+				"min":  0.0,
+				"max":  3600.0,
+			},
+			"lazyfree-lazy-eviction": map[string]interface{}{
+				"type": "boolean",
+			},
+			"maxmemory": map[string]interface{}{
+				"type": "integer",
+				"min":  0.0,
+			},
+		}
+	})
+
+	withRedisConfig := func(config map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"parameters": map[string]interface{}{adapter.RedisConfigParameter: config}}
+	}
+
+	redisConfig := func(output serviceadapter.GenerateManifestOutput) interface{} {
+		return output.Manifest.InstanceGroups[0].Jobs[0].Properties["redis"].(map[interface{}]interface{})["config"]
+	}
+
+	It("sets requested directives and declared defaults", func() {
+		params.RequestParams = withRedisConfig(map[string]interface{}{"timeout": 300.0, "lazyfree-lazy-eviction": true})
+
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisConfig(output)).To(Equal(map[interface{}]interface{}{
+			"maxmemory-policy":       "noeviction",
+			"timeout":                int64(300),
+			"lazyfree-lazy-eviction": true,
+		}))
+	})
+
+	It("accepts integers only bounded by the declared min and max", func() {
+		params.RequestParams = withRedisConfig(map[string]interface{}{"maxmemory": 8589934592.0})
+
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisConfig(output)).To(HaveKeyWithValue("maxmemory", int64(8589934592)))
+	})
+
+	It("keeps directives set before when updating", func() {
+		params.RequestParams = withRedisConfig(map[string]interface{}{"timeout": 300.0, "maxmemory-policy": "allkeys-lru"})
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = withRedisConfig(map[string]interface{}{"timeout": 60.0})
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisConfig(output)).To(Equal(map[interface{}]interface{}{
+			"maxmemory-policy": "allkeys-lru",
+			"timeout":          int64(60),
+		}))
+	})
+
+	It("drops directives the plan no longer declares", func() {
+		params.RequestParams = withRedisConfig(map[string]interface{}{"timeout": 300.0})
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = nil
+		delete(params.Plan.Properties, adapter.RedisConfigDirectivesPlanProperty)
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisConfig(output)).To(BeNil())
+	})
+
+	It("does not accept directives when the plan declares none", func() {
+		delete(params.Plan.Properties, adapter.RedisConfigDirectivesPlanProperty)
+		params.RequestParams = withRedisConfig(map[string]interface{}{"timeout": 300.0})
+
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.UserErrorKind, "unsupported parameter(s) for this service plan: redis_config"))
+	})
+
+	DescribeTable("rejects values the plan does not allow",
+		func(config map[string]interface{}, expectedErr string) {
+			params.RequestParams = withRedisConfig(config)
+			_, err := generator.GenerateManifest(params)
+			Expect(err).To(matchAdapterError(adapter.UserErrorKind, expectedErr))
+		},
+		Entry("undeclared directives", map[string]interface{}{"save": "900 1"},
+			"invalid redis_config: directive save cannot be set for this service plan"),
+		Entry("fractional integers", map[string]interface{}{"timeout": 1.5},
+			"invalid redis_config: directive timeout must be an integer, got 1.5"),
+		Entry("integers out of range", map[string]interface{}{"timeout": 7200.0},
+			"invalid redis_config: directive timeout must be at most 3600, got 7200"),
+		Entry("integers beyond 64 bits", map[string]interface{}{"maxmemory": 1e19},
+			"invalid redis_config: directive maxmemory must be an integer, got 1e+19"),
+		Entry("strings not listed", map[string]interface{}{"maxmemory-policy": "volatile-ttl"},
+			`invalid redis_config: directive maxmemory-policy must be one of noeviction, allkeys-lru, got "volatile-ttl"`),
+		Entry("values of the wrong type", map[string]interface{}{"lazyfree-lazy-eviction": "yes"},
+			"invalid redis_config: directive lazyfree-lazy-eviction must be true or false, got yes"),
+	)
+
+	It("reports every problem with the plan's declaration to operators", func() {
+		params.Plan.Properties[adapter.RedisConfigDirectivesPlanProperty] = map[string]interface{}{
+			"requirepass": map[string]interface{}{"type": "string"},
+			"timeout":     map[string]interface{}{"type": "integer", "min": 10.0, "default": 5.0},
+			"appendonly":  map[string]interface{}{"type": "yes-or-no"},
+		}
+
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "invalid plan property redis_config_directives: "+
+			`directive appendonly type must be one of integer, string, boolean, got "yes-or-no"; `+
+			"directive requirepass is managed by the adapter; "+
+			"directive timeout default must be at least 10, got 5"))
+	})
+})
@@ -4,7 +4,6 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"regexp"
@@ -20,6 +19,8 @@ import (
 const (
 	RedisServerPersistencePropertyKey = "persistence"
 	RedisServerPort                   = 6379
+	RedisServerTLSPortPropertyKey     = "tls_port"
+	RedisServerACLFile                = "/var/vcap/store/redis-server/users.acl"
 	RedisJobName                      = "redis-server"
 	HealthCheckErrandName             = "health-check"
 	CleanupDataErrandName             = "cleanup-data"
@@ -40,34 +41,100 @@ const (
 )
 
 type ManifestGenerator struct {
-	StderrLogger *log.Logger
-	Config       Config
+	StderrLogger   *log.Logger
+	Config         Config
+	AuditLog       AuditLog
+	ReleaseLookups *ReleaseLookups
+	Hooks          Hooks
 }
 
 func (m ManifestGenerator) GenerateManifest(params serviceadapter.GenerateManifestParams) (serviceadapter.GenerateManifestOutput, error) {
+	m.StderrLogger = WithLogFields(m.StderrLogger, LogFields{
+		Operation:  "generate-manifest",
+		Deployment: params.ServiceDeployment.DeploymentName,
+		Plan:       planID(params.RequestParams),
+	})
+
+	output, err := m.generateManifest(params)
+	logAdapterError(m.StderrLogger, err)
+	if m.AuditLog != nil {
+		record := AuditRecord{
+			Operation:  "generate-manifest",
+			Deployment: params.ServiceDeployment.DeploymentName,
+			Plan:       planID(params.RequestParams),
+			Parameters: redactParameters(params.RequestParams.ArbitraryParams()),
+		}
+		if err == nil {
+			record.Changes = manifestChanges(params, output.Manifest, m.Config.RedisInstanceGroupName)
+		}
+		if auditErr := m.AuditLog.Record(auditOutcome(record, err)); auditErr != nil {
+			m.StderrLogger.Println(auditErr.Error())
+		}
+	}
+	return output, err
+}
+
+func (m ManifestGenerator) generateManifest(params serviceadapter.GenerateManifestParams) (serviceadapter.GenerateManifestOutput, error) {
 	ctx := params.RequestParams.ArbitraryContext()
 	platform := params.RequestParams.Platform()
 	if len(ctx) == 0 || platform != "cloudfoundry" {
 		m.StderrLogger.Println("Non Cloud Foundry platform (or pre OSBAPI 2.13) detected")
 	}
 
-	m.StderrLogger.Printf("\n\n[generate-manifest] Service Adapter received the following request context: %#v\n\n", ctx)
+	debugf(m.StderrLogger, "received request context: %v", ctx)
+
+	definition := m.Config.ServiceDefinitionOrDefault()
 
 	arbitraryParameters := params.RequestParams.ArbitraryParams()
-	illegalArbParams := findIllegalArbitraryParams(arbitraryParameters)
+	allowedParams := append(append([]string{}, definition.Parameters...), m.Hooks.Parameters...)
+	if _, declared := params.Plan.Properties[RedisConfigDirectivesPlanProperty]; declared {
+		allowedParams = append(allowedParams, RedisConfigParameter)
+	}
+	if _, declared := params.Plan.Properties[RedisModulesPlanProperty]; declared {
+		allowedParams = append(allowedParams, RedisModulesParameter)
+	}
+	if _, declared := params.Plan.Properties[RedisVersionsPlanProperty]; declared {
+		allowedParams = append(allowedParams, RedisVersionParameter)
+	}
+	illegalArbParams := findIllegalArbitraryParams(arbitraryParameters, allowedParams)
 	if len(illegalArbParams) != 0 {
-		return serviceadapter.GenerateManifestOutput{}, fmt.Errorf("unsupported parameter(s) for this service plan: %s", strings.Join(illegalArbParams, ", "))
+		return serviceadapter.GenerateManifestOutput{}, NewUserError(fmt.Errorf("unsupported parameter(s) for this service plan: %s", strings.Join(illegalArbParams, ", ")))
+	}
+	if err := m.Hooks.validateParameters(arbitraryParameters, params.Plan); err != nil {
+		return serviceadapter.GenerateManifestOutput{}, err
 	}
 
-	if params.PreviousManifest != nil {
-		if err := m.validUpgradePath(*params.PreviousManifest, params.ServiceDeployment.Releases); err != nil {
+	versions, err := RedisVersions(params.Plan.Properties)
+	if err != nil {
+		return serviceadapter.GenerateManifestOutput{}, NewOperatorError(err)
+	}
+	serviceReleases := params.ServiceDeployment.Releases
+	if len(versions) > 0 {
+		previousRelease := ""
+		if params.PreviousManifest != nil {
+			previousRelease = redisServerRelease(*params.PreviousManifest, definition)
+		}
+		version, err := redisVersionForRedisServer(versions, arbitraryParameters, previousRelease, serviceReleases)
+		if err != nil {
 			return serviceadapter.GenerateManifestOutput{}, err
 		}
+		serviceReleases = releasesForRedisVersion(serviceReleases, versions, version, previousRelease)
+	}
+
+	if params.PreviousManifest != nil {
+		// the previous plan's versions were checked when the instance was
+		// deployed with it
+		var previousVersions []RedisVersion
+		if params.PreviousPlan != nil {
+			previousVersions, _ = RedisVersions(params.PreviousPlan.Properties)
+		}
+		if err := m.validUpgradePath(*params.PreviousManifest, serviceReleases, definition, versions, previousVersions); err != nil {
+			return serviceadapter.GenerateManifestOutput{}, NewOperatorError(err)
+		}
 	}
 
 	stemcellAlias := "only-stemcell"
 
-	var err error
 	managedSecretValue := ManagedSecretValue
 	if requestParamsOdbManagedSecret, found := params.RequestParams.ArbitraryParams()[ManagedSecretKey]; found {
 		managedSecretValue = requestParamsOdbManagedSecret.(string)
@@ -79,17 +146,14 @@ func (m ManifestGenerator) GenerateManifest(params serviceadapter.GenerateManife
 		vmExtensionsConfig = requestParamsVMExtensionsConfig.(string)
 	}
 
-	redisServerInstanceGroup := m.findRedisServerInstanceGroup(params.Plan)
-	if redisServerInstanceGroup == nil {
-		m.StderrLogger.Println(fmt.Sprintf("no %s instance group definition found", m.Config.RedisInstanceGroupName))
-		return serviceadapter.GenerateManifestOutput{}, errors.New("Contact your operator, service configuration issue occurred")
+	redisServerDefinition := definition.InstanceGroups[0]
+	if findInstanceGroup(params.Plan, redisServerDefinition.Name) == nil {
+		return serviceadapter.GenerateManifestOutput{}, NewOperatorError(fmt.Errorf("no %s instance group definition found", redisServerDefinition.Name))
 	}
 
 	newSecrets := serviceadapter.ODBManagedSecrets{}
 
-	redisServerNetworks := mapNetworksToBoshNetworks(redisServerInstanceGroup.Networks)
-
-	redisProperties, err := m.redisServerProperties(
+	redisValues, err := m.redisServerValues(
 		params.ServiceDeployment.DeploymentName,
 		params.Plan.Properties,
 		arbitraryParameters,
@@ -102,122 +166,108 @@ func (m ManifestGenerator) GenerateManifest(params serviceadapter.GenerateManife
 		return serviceadapter.GenerateManifestOutput{}, err
 	}
 
-	redisServerJob, err := m.gatherRedisServerJob(params.ServiceDeployment.Releases)
+	var previousRedisProperties map[interface{}]interface{}
+	if params.PreviousManifest != nil {
+		previousRedisProperties = redisPlanProperties(*params.PreviousManifest)
+	}
+	modules, err := redisModulesForRedisServer(params.Plan.Properties, arbitraryParameters, previousRedisProperties)
 	if err != nil {
 		return serviceadapter.GenerateManifestOutput{}, err
 	}
-	redisServerJob.Properties = redisProperties
-
-	redisServerInstanceJobs := []bosh.Job{redisServerJob}
+	// plans with modules record the instance's choice even when it is none,
+	// so updates can tell it from instances created before the plan had any
+	if _, declared := params.Plan.Properties[RedisModulesPlanProperty]; declared {
+		redisValues["modules"] = append([]string{}, moduleNames(modules)...)
+	}
+	if len(modules) > 0 {
+		redisValues["loadmodule"] = modulePaths(modules)
+	}
 
-	if value, ok := params.Plan.Properties["colocated_errand"].(bool); ok && value {
-		var errands []serviceadapter.Errand
-		errands = append(params.Plan.LifecycleErrands.PreDelete, params.Plan.LifecycleErrands.PostDeploy...)
+	values := templateValues{
+		parameters: arbitraryParameters,
+		plan:       params.Plan.Properties,
+		adapter:    redisValues,
+	}
 
-		for _, errand := range errands {
-			if len(errand.Instances) == 0 {
+	var instanceGroups []bosh.InstanceGroup
+	for i, instanceGroupDefinition := range definition.InstanceGroups {
+		planInstanceGroup := findInstanceGroup(params.Plan, instanceGroupDefinition.Name)
+		if planInstanceGroup == nil {
+			if instanceGroupDefinition.Optional {
 				continue
 			}
-			job, err := gatherJob(params.ServiceDeployment.Releases, errand.Name)
+			return serviceadapter.GenerateManifestOutput{}, NewOperatorError(fmt.Errorf("no %s instance group definition found", instanceGroupDefinition.Name))
+		}
+
+		var jobs []bosh.Job
+		for _, jobDefinition := range instanceGroupDefinition.Jobs {
+			job, err := m.generateJob(jobDefinition, serviceReleases, values)
+			if err != nil && i == 0 {
+				err = fmt.Errorf("error gathering redis server job: %s", err)
+			}
 			if err != nil {
-				return serviceadapter.GenerateManifestOutput{}, err
+				return serviceadapter.GenerateManifestOutput{}, NewOperatorError(err)
 			}
-
-			redisServerInstanceJobs = append(redisServerInstanceJobs, job)
+			jobs = append(jobs, job)
 		}
-	}
-
-	var migrations []bosh.Migration
-	for _, m := range redisServerInstanceGroup.MigratedFrom {
-		migrations = append(migrations, bosh.Migration{
-			Name: m.Name,
-		})
-	}
-
-	redisServerVMExtensions, err := m.gatherRedisServerVMExtensions(
-		redisServerInstanceGroup.VMExtensions,
-		vmExtensionsConfig,
-		params.PreviousManifest,
-	)
-	if err != nil {
-		return serviceadapter.GenerateManifestOutput{}, err
-	}
-
-	newRedisInstanceGroup := bosh.InstanceGroup{
-		Name:               redisServerInstanceGroup.Name,
-		Instances:          redisServerInstanceGroup.Instances,
-		Jobs:               redisServerInstanceJobs,
-		VMType:             redisServerInstanceGroup.VMType,
-		VMExtensions:       redisServerVMExtensions,
-		PersistentDiskType: redisServerInstanceGroup.PersistentDiskType,
-		Stemcell:           stemcellAlias,
-		Networks:           redisServerNetworks,
-		AZs:                redisServerInstanceGroup.AZs,
-		MigratedFrom:       migrations,
-	}
-
-	instanceGroups := []bosh.InstanceGroup{newRedisInstanceGroup}
-
-	healthCheckInstanceGroup := findHealthCheckInstanceGroup(params.Plan)
-
-	if healthCheckInstanceGroup != nil {
-		healthCheckProperties := m.healthCheckProperties(params.Plan.Properties)
-
-		healthCheckJob, err := gatherHealthCheckJob(params.ServiceDeployment.Releases)
 
-		if err != nil {
-			return serviceadapter.GenerateManifestOutput{}, err
+		var migrations []bosh.Migration
+		for _, m := range planInstanceGroup.MigratedFrom {
+			migrations = append(migrations, bosh.Migration{
+				Name: m.Name,
+			})
 		}
 
-		healthCheckJobs := []bosh.Job{healthCheckJob}
-		healthCheckNetworks := mapNetworksToBoshNetworks(healthCheckInstanceGroup.Networks)
-
-		instanceGroups = append(instanceGroups, bosh.InstanceGroup{
-			Name:               HealthCheckErrandName,
-			Instances:          healthCheckInstanceGroup.Instances,
-			Jobs:               healthCheckJobs,
-			VMType:             healthCheckInstanceGroup.VMType,
-			VMExtensions:       healthCheckInstanceGroup.VMExtensions,
-			PersistentDiskType: healthCheckInstanceGroup.PersistentDiskType,
-			Stemcell:           stemcellAlias,
-			Networks:           healthCheckNetworks,
-			AZs:                healthCheckInstanceGroup.AZs,
-			Lifecycle:          LifecycleErrandType,
-			Properties:         healthCheckProperties,
-		})
-	}
-
-	cleanupDataInstanceGroup := findCleanupDataInstanceGroup(params.Plan)
+		vmExtensions := planInstanceGroup.VMExtensions
 
-	if cleanupDataInstanceGroup != nil {
-		cleanupDataProperties := m.cleanupDataProperties(params.Plan.Properties)
+		if i == 0 {
+			colocatedErrandJobs, err := m.colocatedErrandJobs(params.Plan, serviceReleases)
+			if err != nil {
+				return serviceadapter.GenerateManifestOutput{}, NewOperatorError(err)
+			}
+			jobs = append(jobs, colocatedErrandJobs...)
+
+			for _, module := range modules {
+				job, err := m.gatherJob(serviceReleases, module.Job)
+				if err != nil {
+					return serviceadapter.GenerateManifestOutput{}, NewOperatorError(fmt.Errorf("error gathering module %s job: %s", module.Name, err))
+				}
+				jobs = append(jobs, job)
+			}
 
-		cleanupDataJob, err := gatherCleanupDataJob(params.ServiceDeployment.Releases)
-		if err != nil {
-			return serviceadapter.GenerateManifestOutput{}, err
+			vmExtensions, err = m.gatherRedisServerVMExtensions(
+				vmExtensions,
+				vmExtensionsConfig,
+				params.PreviousManifest,
+			)
+			if err != nil {
+				return serviceadapter.GenerateManifestOutput{}, err
+			}
 		}
 
-		cleanupDataJobs := []bosh.Job{cleanupDataJob}
-
-		cleanupDataNetworks := mapNetworksToBoshNetworks(cleanupDataInstanceGroup.Networks)
+		var properties map[string]interface{}
+		if instanceGroupDefinition.Lifecycle == LifecycleErrandType {
+			properties = errandProperties(planInstanceGroup.Name, params.Plan.Properties)
+		}
 
 		instanceGroups = append(instanceGroups, bosh.InstanceGroup{
-			Name:               CleanupDataErrandName,
-			Instances:          cleanupDataInstanceGroup.Instances,
-			Jobs:               cleanupDataJobs,
-			VMType:             cleanupDataInstanceGroup.VMType,
-			VMExtensions:       cleanupDataInstanceGroup.VMExtensions,
-			PersistentDiskType: cleanupDataInstanceGroup.PersistentDiskType,
+			Name:               planInstanceGroup.Name,
+			Instances:          planInstanceGroup.Instances,
+			Jobs:               jobs,
+			VMType:             planInstanceGroup.VMType,
+			VMExtensions:       vmExtensions,
+			PersistentDiskType: planInstanceGroup.PersistentDiskType,
 			Stemcell:           stemcellAlias,
-			Networks:           cleanupDataNetworks,
-			AZs:                cleanupDataInstanceGroup.AZs,
-			Lifecycle:          LifecycleErrandType,
-			Properties:         cleanupDataProperties,
+			Networks:           mapNetworksToBoshNetworks(planInstanceGroup.Networks),
+			AZs:                planInstanceGroup.AZs,
+			MigratedFrom:       migrations,
+			Lifecycle:          instanceGroupDefinition.Lifecycle,
+			Properties:         properties,
 		})
 	}
 
 	releases := []bosh.Release{}
-	for _, release := range params.ServiceDeployment.Releases {
+	for _, release := range serviceReleases {
 		releases = append(releases, bosh.Release{
 			Name:    release.Name,
 			Version: release.Version,
@@ -237,27 +287,8 @@ func (m ManifestGenerator) GenerateManifest(params serviceadapter.GenerateManife
 		InstanceGroups: instanceGroups,
 		Update:         generateUpdateBlock(params.Plan.Update, params.PreviousManifest),
 		Properties:     map[string]interface{}{},
-		Tags: map[string]interface{}{
-			"product": "redis",
-		},
-		Variables: []bosh.Variable{
-			{Name: GeneratedSecretVariableName, Type: "password"},
-			{
-				Name:       CertificateVariableName,
-				Type:       "certificate",
-				UpdateMode: "no-overwrite",
-				Options:    map[string]interface{}{"is_ca": true, "common_name": "redis"},
-				Consumes: &bosh.VariableConsumes{
-					AlternativeName: bosh.VariableConsumesLink{
-						From:       "redis-server-link",
-						Properties: map[string]interface{}{"wildcard": true},
-					},
-					CommonName: bosh.VariableConsumesLink{
-						From: "redis-server-link",
-					},
-				},
-			},
-		},
+		Tags:           definition.manifestTags(),
+		Variables:      definition.manifestVariables(),
 	}
 	if useShortDNSAddress, set := params.Plan.Properties["use_short_dns_addresses"]; set {
 		newManifest.Features.UseShortDNSAddresses = bosh.BoolPointer(useShortDNSAddress == true)
@@ -274,17 +305,30 @@ func (m ManifestGenerator) GenerateManifest(params serviceadapter.GenerateManife
 		newConfigs[CloudConfigKey] = vmExtensionsConfig
 	}
 
-	return serviceadapter.GenerateManifestOutput{
+	output := serviceadapter.GenerateManifestOutput{
 		Manifest:          newManifest,
 		ODBManagedSecrets: newSecrets,
 		Configs:           newConfigs,
-	}, nil
+	}
+	if err := m.Hooks.customizeManifest(params, &output); err != nil {
+		return serviceadapter.GenerateManifestOutput{}, err
+	}
+	output.Manifest, err = ApplyManifestOps(output.Manifest, m.Config.ManifestOps, params.Plan)
+	if err != nil {
+		return serviceadapter.GenerateManifestOutput{}, NewOperatorError(err)
+	}
+
+	if err := ValidateManifest(output.Manifest, output.ODBManagedSecrets); err != nil {
+		return serviceadapter.GenerateManifestOutput{}, NewOperatorError(fmt.Errorf("generated manifest is invalid: %s", err))
+	}
+
+	return output, nil
 }
 
-func findIllegalArbitraryParams(arbitraryParams map[string]interface{}) []string {
+func findIllegalArbitraryParams(arbitraryParams map[string]interface{}, allowedParams []string) []string {
 	var illegalParams []string
 	for k, _ := range arbitraryParams {
-		if k == "maxclients" || k == "credhub_secret_path" || k == ManagedSecretKey || k == VMExtensionsConfigKey {
+		if containsString(allowedParams, k) || k == ManagedSecretKey || k == VMExtensionsConfigKey {
 			continue
 		}
 		illegalParams = append(illegalParams, k)
@@ -323,18 +367,6 @@ func findInstanceGroup(plan serviceadapter.Plan, instanceGroupName string) *serv
 	return nil
 }
 
-func (m *ManifestGenerator) findRedisServerInstanceGroup(plan serviceadapter.Plan) *serviceadapter.InstanceGroup {
-	return findInstanceGroup(plan, m.Config.RedisInstanceGroupName)
-}
-
-func findHealthCheckInstanceGroup(plan serviceadapter.Plan) *serviceadapter.InstanceGroup {
-	return findInstanceGroup(plan, HealthCheckErrandName)
-}
-
-func findCleanupDataInstanceGroup(plan serviceadapter.Plan) *serviceadapter.InstanceGroup {
-	return findInstanceGroup(plan, CleanupDataErrandName)
-}
-
 var versionRegexp = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\+dev\.(\d+))?`)
 
 func parseReleaseVersion(versionString string) (int, int, int, error) {
@@ -397,29 +429,52 @@ func generateUpdateBlock(update *serviceadapter.Update, previousManifest *bosh.B
 	}
 }
 
-func gatherJob(releases serviceadapter.ServiceReleases, jobName string) (bosh.Job, error) {
-	release, err := findReleaseForJob(jobName, releases)
+func (m *ManifestGenerator) gatherJob(releases serviceadapter.ServiceReleases, jobName string) (bosh.Job, error) {
+	release, err := m.ReleaseLookups.ReleaseForJob(jobName, releases)
 	if err != nil {
 		return bosh.Job{}, err
 	}
 	return bosh.Job{Name: jobName, Release: release.Name}, nil
 }
 
-func (m *ManifestGenerator) gatherRedisServerJob(releases serviceadapter.ServiceReleases) (bosh.Job, error) {
-	redisServerJob, err := gatherJob(releases, RedisJobName)
+func (m *ManifestGenerator) generateJob(definition JobDefinition, releases serviceadapter.ServiceReleases, values templateValues) (bosh.Job, error) {
+	job, err := m.gatherJob(releases, definition.Name)
 	if err != nil {
-		return bosh.Job{}, errors.New(fmt.Sprintf("error gathering redis server job: %s", err))
+		return bosh.Job{}, err
+	}
+	for _, provider := range definition.CustomProviderDefinitions {
+		job = job.AddCustomProviderDefinition(provider.Name, provider.Type, provider.Properties)
+	}
+	for _, link := range definition.SharedProvides {
+		job = job.AddSharedProvidesLink(link)
+	}
+	job.Properties, err = values.renderProperties(definition.Properties)
+	if err != nil {
+		return bosh.Job{}, err
 	}
-	redisServerJob = redisServerJob.AddCustomProviderDefinition("redis-server-link", "address", nil)
-	return redisServerJob.AddSharedProvidesLink("redis"), nil
+	return job, nil
 }
 
-func gatherHealthCheckJob(releases serviceadapter.ServiceReleases) (bosh.Job, error) {
-	return gatherJob(releases, HealthCheckErrandName)
-}
+// colocatedErrandJobs are the lifecycle errands the plan runs on the Redis
+// server instances, when it sets colocated_errand.
+func (m *ManifestGenerator) colocatedErrandJobs(plan serviceadapter.Plan, releases serviceadapter.ServiceReleases) ([]bosh.Job, error) {
+	if value, ok := plan.Properties["colocated_errand"].(bool); !ok || !value {
+		return nil, nil
+	}
 
-func gatherCleanupDataJob(releases serviceadapter.ServiceReleases) (bosh.Job, error) {
-	return gatherJob(releases, CleanupDataErrandName)
+	var jobs []bosh.Job
+	errands := append(plan.LifecycleErrands.PreDelete, plan.LifecycleErrands.PostDeploy...)
+	for _, errand := range errands {
+		if len(errand.Instances) == 0 {
+			continue
+		}
+		job, err := m.gatherJob(releases, errand.Name)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
 }
 
 func findReleaseForJob(requiredJob string, releases serviceadapter.ServiceReleases) (serviceadapter.ServiceRelease, error) {
@@ -457,7 +512,9 @@ func redisPlanProperties(manifest bosh.BoshManifest) map[interface{}]interface{}
 	return jobProperties.(map[interface{}]interface{})
 }
 
-func (m ManifestGenerator) redisServerProperties(
+// redisServerValues works out the ${adapter.key} template values, carrying
+// them over from the previous manifest where they must not change.
+func (m ManifestGenerator) redisServerValues(
 	deploymentName string,
 	planProperties serviceadapter.Properties,
 	arbitraryParams map[string]interface{},
@@ -477,22 +534,62 @@ func (m ManifestGenerator) redisServerProperties(
 
 	password, err := passwordForRedisServer(previousRedisProperties)
 	if err != nil {
-		return nil, err
+		return nil, NewInternalError(err)
 	}
 
 	managedSecretKey := managedSecretKeyForRedisServer(previousRedisProperties, m.Config.IgnoreODBManagedSecretOnUpdate)
 
 	maxClients := maxClientsForRedisServer(arbitraryParams, previousRedisProperties)
 
-	properties := map[interface{}]interface{}{
-		"persistence":      persistence,
-		"password":         password,
-		"maxclients":       maxClients,
-		GeneratedSecretKey: "((" + GeneratedSecretVariableName + "))",
-		ManagedSecretKey:   managedSecretKey,
-		"ca_cert":          "((" + CertificateVariableName + ".ca))",
-		"certificate":      "((" + CertificateVariableName + ".certificate))",
-		"private_key":      "((" + CertificateVariableName + ".private_key))",
+	config, err := redisConfigForRedisServer(planProperties, arbitraryParams, previousRedisProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	commandRestrictions, err := CommandRestrictionsForPlan(planProperties)
+	if err == nil && m.Config.ACLBindingsEnabled {
+		err = commandRestrictions.checkACLBindings()
+	}
+	if err != nil {
+		return nil, NewOperatorError(err)
+	}
+
+	properties := map[string]interface{}{
+		"persistence":    persistence,
+		"password":       password,
+		"maxclients":     maxClients,
+		ManagedSecretKey: managedSecretKey,
+	}
+
+	if tlsPort, err := tlsPortForPlan(planProperties); err != nil {
+		return nil, NewOperatorError(err)
+	} else if tlsPort != 0 {
+		properties["tls_port"] = tlsPort
+	}
+
+	if config != nil {
+		properties["config"] = config
+	}
+
+	if m.Config.ACLBindingsEnabled {
+		// binding users are kept on the persistent disk, so they survive
+		// restarts and recreates
+		properties["aclfile"] = RedisServerACLFile
+		denied := commandRestrictions.deniedCommands()
+		if len(denied) > 0 {
+			properties["denied_commands"] = denied
+		}
+		// the adapter cannot reach the instance here, so operators are told
+		// when existing binding users will catch up
+		previousDenied := deniedCommandsForRedisServer(previousRedisProperties)
+		if previousManifest != nil && strings.Join(denied, " ") != strings.Join(previousDenied, " ") {
+			m.StderrLogger.Printf("%s: binding users are now denied %v instead of %v; existing binding users are updated on the next bind or unbind of the instance", deploymentName, denied, previousDenied)
+		}
+		if denied := commandRestrictions.defaultUserDeniedCommands(); len(denied) > 0 {
+			properties["default_user_denied_commands"] = denied
+		}
+	} else if renames := commandRestrictions.renameCommands(); renames != nil {
+		properties["rename_commands"] = renames
 	}
 
 	if serviceInstanceClient != nil {
@@ -523,9 +620,7 @@ func (m ManifestGenerator) redisServerProperties(
 		properties["secret"] = secret
 	}
 
-	return map[string]interface{}{
-		"redis": properties,
-	}, nil
+	return properties, nil
 }
 
 func hasPreviousServiceInstanceClient(previousManifestProperties map[interface{}]interface{}) (interface{}, bool) {
@@ -558,6 +653,26 @@ func passwordForRedisServer(previousManifestProperties map[interface{}]interface
 	return CurrentPasswordGenerator()
 }
 
+// tlsPortForPlan is the port the plan has the Redis server listen for TLS
+// connections on, passed through to the redis-server job's tls_port, or 0
+// when the plan leaves TLS off.
+func tlsPortForPlan(planProperties serviceadapter.Properties) (int, error) {
+	value, found := planProperties[RedisServerTLSPortPropertyKey]
+	if !found || value == nil {
+		return 0, nil
+	}
+	var tlsPort int
+	if err := decodePlanProperty(value, &tlsPort); err != nil || tlsPort <= 0 || tlsPort > 65535 {
+		return 0, fmt.Errorf("invalid plan property %s: must be a port number, got %v", RedisServerTLSPortPropertyKey, value)
+	}
+	return tlsPort, nil
+}
+
+func tlsPortForRedisServer(manifestProperties map[interface{}]interface{}) int {
+	tlsPort, _ := manifestProperties["tls_port"].(int)
+	return tlsPort
+}
+
 func maxClientsForRedisServer(arbitraryParams map[string]interface{}, previousManifestProperties map[interface{}]interface{}) int {
 	if configuredMax, ok := arbitraryParams["maxclients"]; ok {
 		return int(configuredMax.(float64))
@@ -570,8 +685,7 @@ func maxClientsForRedisServer(arbitraryParams map[string]interface{}, previousMa
 func (m *ManifestGenerator) persistenceForRedisServer(planProperties serviceadapter.Properties) (string, error) {
 	persistenceConfig, found := planProperties[RedisServerPersistencePropertyKey]
 	if !found {
-		m.StderrLogger.Println(fmt.Sprintf("the plan property '%s' is missing", RedisServerPersistencePropertyKey))
-		return "", errors.New("")
+		return "", NewOperatorError(fmt.Errorf("the plan property '%s' is missing", RedisServerPersistencePropertyKey))
 	}
 	persistence := "no"
 	if persistenceConfig.(bool) {
@@ -580,18 +694,6 @@ func (m *ManifestGenerator) persistenceForRedisServer(planProperties serviceadap
 	return persistence, nil
 }
 
-func (m *ManifestGenerator) healthCheckProperties(
-	planProperties serviceadapter.Properties,
-) map[string]interface{} {
-	return errandProperties(HealthCheckErrandName, planProperties)
-}
-
-func (m *ManifestGenerator) cleanupDataProperties(
-	planProperties serviceadapter.Properties,
-) map[string]interface{} {
-	return errandProperties(CleanupDataErrandName, planProperties)
-}
-
 func errandProperties(
 	errandName string,
 	planProperties serviceadapter.Properties,
@@ -635,12 +737,19 @@ func findOldManifestRedisRelease(redisReleaseName string, previousManifestReleas
 	return bosh.Release{}, fmt.Errorf("no release with name %s found in previous manifest", redisReleaseName)
 }
 
-func (m *ManifestGenerator) validUpgradePath(previousManifest bosh.BoshManifest, serviceReleases serviceadapter.ServiceReleases) error {
-	newRedisRelease, err := findReleaseForJob(RedisJobName, serviceReleases)
+func (m *ManifestGenerator) validUpgradePath(previousManifest bosh.BoshManifest, serviceReleases serviceadapter.ServiceReleases, definition ServiceDefinition, versions, previousVersions []RedisVersion) error {
+	newRedisRelease, err := m.ReleaseLookups.ReleaseForJob(definition.redisServerJobName(), serviceReleases)
 	if err != nil {
 		return err
 	}
 
+	// Moving to one of the plan's versions, e.g. from Redis to Valkey, is
+	// checked against the order of the versions rather than release versions
+	oldRedisReleaseName := redisServerRelease(previousManifest, definition)
+	if oldRedisReleaseName != newRedisRelease.Name && isRedisVersionRelease(versions, newRedisRelease.Name) {
+		return checkRedisVersionOrder(oldRedisReleaseName, newRedisRelease.Name, versions, previousVersions)
+	}
+
 	oldRedisRelease, err := findOldManifestRedisRelease(newRedisRelease.Name, previousManifest.Releases)
 	if err != nil {
 		return err
@@ -694,7 +803,7 @@ func (m *ManifestGenerator) gatherRedisServerVMExtensions(
 	if vmExtensionsConfig != "" {
 		vmExtensionNames, err := parseVMExtensionsConfig(vmExtensionsConfig)
 		if err != nil {
-			return vmExtensions, err
+			return vmExtensions, NewUserError(fmt.Errorf("invalid %s parameter: %s", VMExtensionsConfigKey, err))
 		}
 		vmExtensions = append(vmExtensions, vmExtensionNames...)
 	} else if previousManifest != nil {
@@ -132,6 +132,34 @@ var _ = Describe("Redis Service Adapter", func() {
 			).To(Equal("no"))
 		})
 
+		It("sets the tls port the plan asks for", func() {
+			highMemoryPlan.Properties["tls_port"] = 16380
+
+			generated, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, highMemoryPlan, defaultRequestParameters, nil, nil, nil, nil, nil)
+
+			Expect(generateErr).NotTo(HaveOccurred())
+			Expect(
+				generated.Manifest.InstanceGroups[0].Jobs[0].Properties["redis"].(map[interface{}]interface{})["tls_port"],
+			).To(Equal(16380))
+		})
+
+		It("fails when the plan's tls port is not a port", func() {
+			highMemoryPlan.Properties["tls_port"] = "yes"
+
+			_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, highMemoryPlan, defaultRequestParameters, nil, nil, nil, nil, nil)
+
+			Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, "invalid plan property tls_port: must be a port number, got yes"))
+		})
+
+		It("does not set the tls port by default", func() {
+			generated, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, highMemoryPlan, defaultRequestParameters, nil, nil, nil, nil, nil)
+
+			Expect(generateErr).NotTo(HaveOccurred())
+			Expect(
+				generated.Manifest.InstanceGroups[0].Jobs[0].Properties["redis"].(map[interface{}]interface{}),
+			).NotTo(HaveKey("tls_port"))
+		})
+
 		Context("validation of 'context' property", func() {
 
 			DescribeTable("logging validation of context and platform when requestParams is",
@@ -260,6 +288,26 @@ var _ = Describe("Redis Service Adapter", func() {
 			Expect(generated.Manifest.InstanceGroups[0].Jobs).To(HaveLen(2))
 		})
 
+		It("fails when colocated errands collide", func() {
+			oldManifest := createDefaultOldManifest()
+
+			plan := serviceadapter.Plan{
+				LifecycleErrands: serviceadapter.LifecycleErrands{
+					PostDeploy: []serviceadapter.Errand{{Name: "health-check", Instances: []string{"redis-server"}}},
+					PreDelete:  []serviceadapter.Errand{{Name: "health-check", Instances: []string{"redis-server"}}},
+				},
+				Properties: map[string]interface{}{
+					"persistence":      true,
+					"colocated_errand": true,
+				},
+				InstanceGroups: dedicatedPlan.InstanceGroups[:1],
+			}
+
+			_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, plan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
+
+			Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, "generated manifest is invalid: instance group redis-server has job health-check more than once"))
+		})
+
 		It("contains only one instance group and multiple jobs, when `colocated_errand` property is set to true and pre_delete has been configured", func() {
 			oldManifest := createDefaultOldManifest()
 
@@ -686,7 +734,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				_, generateErr := generateManifest(manifestGenerator, missingHealthCheckJobReleases, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 
 				Expect(generateErr).To(HaveOccurred())
-				Expect(generateErr).To(MatchError(fmt.Sprintf(
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, fmt.Sprintf(
 					"no release provided for job %s",
 					adapter.HealthCheckErrandName,
 				)))
@@ -709,7 +757,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				_, generateErr := generateManifest(manifestGenerator, missingRedisJobRelease, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 
 				Expect(generateErr).To(HaveOccurred())
-				Expect(generateErr).To(MatchError("no release provided for job redis-server"))
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, "no release provided for job redis-server"))
 			})
 
 			It("returns an error when the cleanup data job is missing from the service releases", func() {
@@ -729,7 +777,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				_, generateErr := generateManifest(manifestGenerator, missingCleanupDataJobRelease, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 
 				Expect(generateErr).To(HaveOccurred())
-				Expect(generateErr).To(MatchError(fmt.Sprintf(
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, fmt.Sprintf(
 					"no release provided for job %s",
 					adapter.CleanupDataErrandName,
 				)))
@@ -746,7 +794,7 @@ var _ = Describe("Redis Service Adapter", func() {
 
 				_, generateErr := generateManifest(manifestGenerator, multipleServiceReleases, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 
-				Expect(generateErr).To(MatchError(fmt.Sprintf("job %s defined in multiple releases: some-release-name, some-other-release", ProvidedRedisServerInstanceGroupName)))
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, fmt.Sprintf("job %s defined in multiple releases: some-release-name, some-other-release", ProvidedRedisServerInstanceGroupName)))
 			})
 
 			It("returns an error with a message for the cli user when a plan does not have an instance group named redis-server", func() {
@@ -759,7 +807,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, planWithoutExpectedInstanceGroupName, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 
 				Expect(generateErr).To(HaveOccurred())
-				Expect(generateErr).To(MatchError(ContainSubstring("Contact your operator, service configuration issue occurred")))
+				Expect(generateErr).To(MatchError(adapter.OperatorErrorMessage))
 				Expect(stderr).To(gbytes.Say("no redis-server instance group definition found"))
 			})
 
@@ -768,7 +816,7 @@ var _ = Describe("Redis Service Adapter", func() {
 
 				_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, planWithPropertyRemoved(dedicatedPlan, "persistence"), defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 				Expect(generateErr).To(HaveOccurred())
-				Expect(generateErr).To(MatchError(""))
+				Expect(generateErr).To(MatchError(adapter.OperatorErrorMessage))
 				Expect(stderr).To(gbytes.Say("the plan property 'persistence' is missing"))
 			})
 
@@ -778,7 +826,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				oldManifest := createDefaultOldManifest()
 
 				_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
-				Expect(generateErr).To(MatchError("oi is not a valid BOSH release version"))
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, "oi is not a valid BOSH release version"))
 			})
 
 			It("returns an error when the old release version (of the release that provides redis-server) cannot be parsed", func() {
@@ -786,7 +834,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				oldManifest.Releases[0].Version = "oi"
 
 				_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
-				Expect(generateErr).To(MatchError("oi is not a valid BOSH release version"))
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, "oi is not a valid BOSH release version"))
 			})
 
 			It("returns an error when the old manifest does not contain any releases with the same name as the configured release that provides redis-server job", func() {
@@ -794,7 +842,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				oldManifest.Releases[0].Name = "i-dont-exist-in-newer-config"
 
 				_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
-				Expect(generateErr).To(MatchError("no release with name some-release-name found in previous manifest"))
+				Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, "no release with name some-release-name found in previous manifest"))
 			})
 
 			It("generates the expected manifest when the old manifest is valid", func() {
@@ -878,7 +926,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				_, generatedErr := generateManifest(manifestGenerator, defaultServiceReleases, updatedDedicatedPlan, map[string]interface{}{}, &oldManifest, nil, nil, nil, nil)
 
 				Expect(generatedErr).To(HaveOccurred())
-				Expect(generatedErr).To(MatchError("Contact your operator, service configuration issue occurred"))
+				Expect(generatedErr).To(MatchError(adapter.OperatorErrorMessage))
 				Expect(stderr).To(gbytes.Say("no foo instance group definition found"))
 			})
 
@@ -887,7 +935,7 @@ var _ = Describe("Redis Service Adapter", func() {
 				serviceReleaseWithMissingJobName[0].Jobs = []string{"overrides-redis-server", "health-check", "cleanup-data"}
 
 				_, generatedErr := generateManifest(manifestGenerator, serviceReleaseWithMissingJobName, dedicatedPlan, map[string]interface{}{}, nil, nil, nil, nil, nil)
-				Expect(generatedErr).To(MatchError("error gathering redis server job: no release provided for job redis-server"))
+				Expect(generatedErr).To(matchAdapterError(adapter.OperatorErrorKind, "error gathering redis server job: no release provided for job redis-server"))
 			})
 		})
 
@@ -967,7 +1015,11 @@ var _ = Describe("Redis Service Adapter", func() {
 						"plan_secret": "plansecret",
 						"persistence": true,
 					},
-					InstanceGroups: []serviceadapter.InstanceGroup{{Name: config.RedisInstanceGroupName}},
+					InstanceGroups: []serviceadapter.InstanceGroup{{
+						Name:     config.RedisInstanceGroupName,
+						Networks: []string{"some-network"},
+						AZs:      []string{"some-az"},
+					}},
 				}
 			})
 
@@ -1026,7 +1078,11 @@ var _ = Describe("Redis Service Adapter", func() {
 					Properties: map[string]interface{}{
 						"persistence": true,
 					},
-					InstanceGroups: []serviceadapter.InstanceGroup{{Name: config.RedisInstanceGroupName}},
+					InstanceGroups: []serviceadapter.InstanceGroup{{
+						Name:     config.RedisInstanceGroupName,
+						Networks: []string{"some-network"},
+						AZs:      []string{"some-az"},
+					}},
 				}
 				provisionManifestOutput, err := generateManifest(manifestGenerator, defaultServiceReleases, planWithoutSecret, map[string]interface{}{}, nil, nil, nil, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
@@ -1079,7 +1135,7 @@ var _ = Describe("Redis Service Adapter", func() {
 
 						_, generateErr := generateManifest(manifestGenerator, defaultServiceReleases, dedicatedPlan, defaultRequestParameters, &oldManifest, nil, nil, nil, nil)
 						if t.returnsError {
-							Expect(generateErr).To(MatchError(errorString))
+							Expect(generateErr).To(matchAdapterError(adapter.OperatorErrorKind, errorString))
 						} else {
 							Expect(generateErr).NotTo(HaveOccurred())
 						}
@@ -0,0 +1,162 @@
+package adapter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const (
+	// RedisModulesPlanProperty declares the Redis modules users of a plan may
+	// enable, and the jobs of the releases delivering them, e.g.
+	//
+	//	redis_modules:
+	//	  json:
+	//	    job: redisjson
+	//	    path: /var/vcap/packages/redisjson/librejson.so
+	//	    default: true
+	//	  bloom:
+	//	    job: redisbloom
+	//	    path: /var/vcap/packages/redisbloom/redisbloom.so
+	RedisModulesPlanProperty = "redis_modules"
+
+	// RedisModulesParameter is the arbitrary parameter users pick modules
+	// with, e.g. {"modules": ["json", "bloom"]}.
+	RedisModulesParameter = "modules"
+)
+
+var moduleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// RedisModule is a module the Redis server loads from a job colocated with
+// it. Default modules are enabled for new instances that do not pick any.
+type RedisModule struct {
+	Name        string `json:"-"`
+	Job         string `json:"job"`
+	Path        string `json:"path"`
+	Default     bool   `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// RedisModules returns the modules the plan declares, keyed by name, or an
+// error describing every problem with the declaration.
+func RedisModules(planProperties serviceadapter.Properties) (map[string]RedisModule, error) {
+	declaration, found := planProperties[RedisModulesPlanProperty]
+	if !found || declaration == nil {
+		return nil, nil
+	}
+
+	var modules map[string]RedisModule
+	if err := decodePlanProperty(declaration, &modules); err != nil {
+		return nil, fmt.Errorf("invalid plan property %s: %s", RedisModulesPlanProperty, err)
+	}
+
+	var problems []string
+	for _, name := range sortedModuleNames(modules) {
+		module := modules[name]
+		module.Name = name
+		modules[name] = module
+		if !moduleNameRegexp.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("module %q must be named with lower case letters, digits, - and _", name))
+		}
+		if module.Job == "" {
+			problems = append(problems, fmt.Sprintf("module %s has no job", name))
+		}
+		if !path.IsAbs(module.Path) {
+			problems = append(problems, fmt.Sprintf("module %s path must be absolute, got %q", name, module.Path))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid plan property %s: %s", RedisModulesPlanProperty, strings.Join(problems, "; "))
+	}
+	return modules, nil
+}
+
+// redisModulesForRedisServer works out the modules the Redis server loads:
+// those the user picked, else those of the previous manifest, else, when the
+// previous manifest never had modules, the plan's defaults. Modules the plan
+// no longer declares are dropped.
+func redisModulesForRedisServer(planProperties serviceadapter.Properties, arbitraryParams map[string]interface{}, previousManifestProperties map[interface{}]interface{}) ([]RedisModule, error) {
+	declared, err := RedisModules(planProperties)
+	if err != nil {
+		return nil, NewOperatorError(err)
+	}
+
+	var names []string
+	if requested, found := arbitraryParams[RedisModulesParameter]; found {
+		requestedList, ok := requested.([]interface{})
+		if !ok {
+			return nil, NewUserError(fmt.Errorf("%s must be a list of module names", RedisModulesParameter))
+		}
+		var unknown []string
+		for _, name := range requestedList {
+			nameString, ok := name.(string)
+			if _, isDeclared := declared[nameString]; !ok || !isDeclared {
+				unknown = append(unknown, fmt.Sprint(name))
+				continue
+			}
+			names = append(names, nameString)
+		}
+		if len(unknown) > 0 {
+			return nil, NewUserError(fmt.Errorf(
+				"unsupported module(s) for this service plan: %s, available modules are: %s",
+				strings.Join(unknown, ", "), strings.Join(sortedModuleNames(declared), ", "),
+			))
+		}
+	} else if _, chosen := previousManifestProperties["modules"]; chosen {
+		for _, name := range modulesForRedisServer(previousManifestProperties) {
+			if _, isDeclared := declared[name]; isDeclared {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name, module := range declared {
+			if module.Default {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	var modules []RedisModule
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		modules = append(modules, declared[name])
+	}
+	return modules, nil
+}
+
+func moduleNames(modules []RedisModule) []string {
+	var names []string
+	for _, module := range modules {
+		names = append(names, module.Name)
+	}
+	return names
+}
+
+func modulePaths(modules []RedisModule) []string {
+	var paths []string
+	for _, module := range modules {
+		paths = append(paths, module.Path)
+	}
+	return paths
+}
+
+// modulesForRedisServer reads back the enabled modules of a manifest.
+func modulesForRedisServer(manifestProperties map[interface{}]interface{}) []string {
+	return stringList(manifestProperties["modules"])
+}
+
+func sortedModuleNames(modules map[string]RedisModule) []string {
+	var names []string
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
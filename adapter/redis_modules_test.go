@@ -0,0 +1,134 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Redis modules", func() {
+	var (
+		generator adapter.ManifestGenerator
+		params    serviceadapter.GenerateManifestParams
+	)
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{
+			Config:       config,
+			StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0),
+		}
+
+		var err error
+		params, err = adapter.LoadRenderInputs(adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		params.ServiceDeployment.Releases = append(params.ServiceDeployment.Releases, serviceadapter.ServiceRelease{
+			Name:    "redis-modules",
+			Version: "2",
+			Jobs:    []string{"redisjson", "redisbloom"},
+		})
+		params.Plan.Properties[adapter.RedisModulesPlanProperty] = map[string]interface{}{
+			"json":  map[string]interface{}{"job": "redisjson", "path": "/var/vcap/packages/redisjson/librejson.so", "default": true},
+			"bloom": map[string]interface{}{"job": "redisbloom", "path": "/var/vcap/packages/redisbloom/redisbloom.so"},
+		}
+	})
+
+	withModules := func(modules ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"parameters": map[string]interface{}{adapter.RedisModulesParameter: modules}}
+	}
+
+	redisProperties := func(manifest bosh.BoshManifest) map[interface{}]interface{} {
+		return manifest.InstanceGroups[0].Jobs[0].Properties["redis"].(map[interface{}]interface{})
+	}
+
+	It("colocates and loads the plan's default modules", func() {
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Manifest.InstanceGroups[0].Jobs).To(ContainElement(bosh.Job{Name: "redisjson", Release: "redis-modules"}))
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("modules", []string{"json"}))
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("loadmodule", []string{"/var/vcap/packages/redisjson/librejson.so"}))
+	})
+
+	It("loads the modules users pick, and keeps them when updating", func() {
+		params.RequestParams = withModules("bloom", "json")
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containsJobName(output.Manifest.InstanceGroups[0].Jobs, "redisbloom")).To(BeTrue())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("modules", []string{"bloom", "json"}))
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = nil
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("modules", []string{"bloom", "json"}))
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = withModules()
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("modules", BeEmpty()))
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("loadmodule"))
+		Expect(containsJobName(output.Manifest.InstanceGroups[0].Jobs, "redisbloom")).To(BeFalse())
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = nil
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("modules", BeEmpty()))
+	})
+
+	It("loads the default modules on instances created before the plan had modules", func() {
+		modules := params.Plan.Properties[adapter.RedisModulesPlanProperty]
+		delete(params.Plan.Properties, adapter.RedisModulesPlanProperty)
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).NotTo(HaveKey("modules"))
+
+		params.PreviousManifest = &output.Manifest
+		params.Plan.Properties[adapter.RedisModulesPlanProperty] = modules
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisProperties(output.Manifest)).To(HaveKeyWithValue("modules", []string{"json"}))
+		Expect(containsJobName(output.Manifest.InstanceGroups[0].Jobs, "redisjson")).To(BeTrue())
+	})
+
+	It("rejects modules the plan does not declare", func() {
+		params.RequestParams = withModules("json", "search")
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.UserErrorKind,
+			"unsupported module(s) for this service plan: search, available modules are: bloom, json"))
+	})
+
+	It("fails when no release provides a module's job", func() {
+		params.ServiceDeployment.Releases = params.ServiceDeployment.Releases[:1]
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "error gathering module json job: no release provided for job redisjson"))
+	})
+
+	It("lists the enabled modules in binding credentials", func() {
+		binder := adapter.Binder{StderrLogger: log.New(GinkgoWriter, "", 0)}
+		binding, err := binder.CreateBinding(serviceadapter.CreateBindingParams{
+			DeploymentTopology: bosh.BoshVMs{"redis-server": []string{"127.0.0.1"}},
+			Manifest: bosh.BoshManifest{
+				InstanceGroups: []bosh.InstanceGroup{{
+					Jobs: []bosh.Job{{
+						Properties: map[string]interface{}{
+							"redis": map[interface{}]interface{}{"password": "supersecret", "modules": []interface{}{"bloom", "json"}},
+						},
+					}},
+				}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding.Credentials).To(HaveKeyWithValue("modules", []string{"bloom", "json"}))
+	})
+})
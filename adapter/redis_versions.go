@@ -0,0 +1,215 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const (
+	// RedisVersionsPlanProperty lists the Redis server versions or flavors a
+	// plan offers, each delivered by its own release, from oldest to newest,
+	// e.g.
+	//
+	//	redis_versions:
+	//	- name: "6"
+	//	  release: redis-6
+	//	- name: "7"
+	//	  release: redis-7
+	//	  default: true
+	//	- name: valkey-8
+	//	  release: valkey
+	//
+	// Instances can only move down the list, so a Redis 7 instance can become
+	// a Valkey instance but never a Redis 6 one.
+	RedisVersionsPlanProperty = "redis_versions"
+
+	// RedisVersionParameter is the arbitrary parameter users pick a version
+	// with, e.g. {"redis_version": "7"}.
+	RedisVersionParameter = "redis_version"
+)
+
+// RedisVersion is a Redis server version or flavor a plan offers. New
+// instances that do not pick one get the default version, or the first one
+// when none is marked default.
+type RedisVersion struct {
+	Name        string `json:"name"`
+	Release     string `json:"release"`
+	Default     bool   `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// RedisVersions returns the versions the plan offers, oldest first, or an
+// error describing every problem with them.
+func RedisVersions(planProperties serviceadapter.Properties) ([]RedisVersion, error) {
+	declaration, found := planProperties[RedisVersionsPlanProperty]
+	if !found || declaration == nil {
+		return nil, nil
+	}
+
+	var versions []RedisVersion
+	if err := decodePlanProperty(declaration, &versions); err != nil {
+		return nil, fmt.Errorf("invalid plan property %s: %s", RedisVersionsPlanProperty, err)
+	}
+
+	var problems []string
+	names := map[string]bool{}
+	releases := map[string]bool{}
+	defaults := 0
+	for i, version := range versions {
+		if version.Name == "" {
+			problems = append(problems, fmt.Sprintf("version %d has no name", i))
+		} else if names[version.Name] {
+			problems = append(problems, fmt.Sprintf("version %s is listed more than once", version.Name))
+		}
+		if version.Release == "" {
+			problems = append(problems, fmt.Sprintf("version %d has no release", i))
+		} else if releases[version.Release] {
+			problems = append(problems, fmt.Sprintf("release %s delivers more than one version", version.Release))
+		}
+		names[version.Name] = true
+		releases[version.Release] = true
+		if version.Default {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		problems = append(problems, "more than one version is marked default")
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid plan property %s: %s", RedisVersionsPlanProperty, strings.Join(problems, "; "))
+	}
+	return versions, nil
+}
+
+// redisVersionForRedisServer picks the version the user asks for, else the
+// one the instance runs, else the default one. Users cannot pick a version
+// listed before the one the instance runs.
+//
+// Instances running a release the plan does not list, because they predate
+// redis_versions or their version was dropped from it, can move to any
+// version the user picks. Otherwise they keep their release while the broker
+// still deploys it, since moving them to the default version could be a
+// downgrade.
+func redisVersionForRedisServer(versions []RedisVersion, arbitraryParams map[string]interface{}, previousRelease string, releases serviceadapter.ServiceReleases) (RedisVersion, error) {
+	previous := -1
+	for i, version := range versions {
+		if previousRelease != "" && version.Release == previousRelease {
+			previous = i
+		}
+	}
+
+	if requested, found := arbitraryParams[RedisVersionParameter]; found {
+		name, ok := requested.(string)
+		if !ok {
+			return RedisVersion{}, NewUserError(fmt.Errorf("%s must be a string", RedisVersionParameter))
+		}
+		for i, version := range versions {
+			if version.Name != name {
+				continue
+			}
+			if previous > i {
+				return RedisVersion{}, NewUserError(fmt.Errorf(
+					"cannot change %s from %s to %s: instances can only be upgraded",
+					RedisVersionParameter, versions[previous].Name, name,
+				))
+			}
+			return version, nil
+		}
+		return RedisVersion{}, NewUserError(fmt.Errorf(
+			"unsupported %s %q for this service plan, available versions are: %s",
+			RedisVersionParameter, name, strings.Join(redisVersionNames(versions), ", "),
+		))
+	}
+
+	if previous >= 0 {
+		return versions[previous], nil
+	}
+	if previousRelease != "" {
+		for _, release := range releases {
+			if release.Name == previousRelease {
+				return RedisVersion{Release: previousRelease}, nil
+			}
+		}
+		return RedisVersion{}, NewOperatorError(fmt.Errorf(
+			"the instance runs release %s, which is neither one of the plan's %s nor deployed any more; set %s to one of: %s",
+			previousRelease, RedisVersionsPlanProperty, RedisVersionParameter, strings.Join(redisVersionNames(versions), ", "),
+		))
+	}
+	for _, version := range versions {
+		if version.Default {
+			return version, nil
+		}
+	}
+	return versions[0], nil
+}
+
+// releasesForRedisVersion drops the releases of the versions other than
+// selected, and the one the instance runs when it moves to selected, so that
+// jobs every version ships, such as redis-server, are found in a single
+// release.
+func releasesForRedisVersion(releases serviceadapter.ServiceReleases, versions []RedisVersion, selected RedisVersion, previousRelease string) serviceadapter.ServiceReleases {
+	var selectedReleases serviceadapter.ServiceReleases
+	for _, release := range releases {
+		if release.Name != selected.Release && (isRedisVersionRelease(versions, release.Name) || release.Name == previousRelease) {
+			continue
+		}
+		selectedReleases = append(selectedReleases, release)
+	}
+	return selectedReleases
+}
+
+// checkRedisVersionOrder rejects moving an instance from oldRelease to
+// newRelease when the plan, or the plan the instance was on before a plan
+// change, lists newRelease before oldRelease. Releases neither plan lists can
+// move anywhere.
+func checkRedisVersionOrder(oldRelease, newRelease string, versionLists ...[]RedisVersion) error {
+	for _, versions := range versionLists {
+		oldIndex, newIndex := redisVersionIndex(versions, oldRelease), redisVersionIndex(versions, newRelease)
+		if oldIndex > newIndex && newIndex >= 0 {
+			return fmt.Errorf(
+				"error generating manifest: cannot move the instance from version %s to %s: instances can only be upgraded",
+				versions[oldIndex].Name, versions[newIndex].Name,
+			)
+		}
+	}
+	return nil
+}
+
+func isRedisVersionRelease(versions []RedisVersion, releaseName string) bool {
+	return redisVersionIndex(versions, releaseName) >= 0
+}
+
+func redisVersionIndex(versions []RedisVersion, releaseName string) int {
+	for i, version := range versions {
+		if version.Release == releaseName {
+			return i
+		}
+	}
+	return -1
+}
+
+// redisServerRelease is the release the Redis server job of manifest comes
+// from, or "" when the manifest has no Redis server job.
+func redisServerRelease(manifest bosh.BoshManifest, definition ServiceDefinition) string {
+	instanceGroup := findInstanceGroupFromPreviousManifest(manifest, definition.InstanceGroups[0].Name)
+	if instanceGroup == nil {
+		return ""
+	}
+	for _, job := range instanceGroup.Jobs {
+		if job.Name == definition.redisServerJobName() {
+			return job.Release
+		}
+	}
+	return ""
+}
+
+func redisVersionNames(versions []RedisVersion) []string {
+	var names []string
+	for _, version := range versions {
+		names = append(names, version.Name)
+	}
+	return names
+}
@@ -0,0 +1,214 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Redis versions", func() {
+	var (
+		generator adapter.ManifestGenerator
+		params    serviceadapter.GenerateManifestParams
+	)
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{
+			Config:       config,
+			StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0),
+		}
+
+		var err error
+		params, err = adapter.LoadRenderInputs(adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		params.ServiceDeployment.Releases = serviceadapter.ServiceReleases{
+			{Name: "redis-6", Version: "6.2", Jobs: []string{"redis-server", "health-check"}},
+			{Name: "redis-7", Version: "7.4", Jobs: []string{"redis-server", "health-check"}},
+			{Name: "valkey", Version: "8.0", Jobs: []string{"redis-server", "health-check"}},
+			{Name: "syslog", Version: "12", Jobs: []string{"syslog-forwarder"}},
+		}
+		params.Plan.Properties[adapter.RedisVersionsPlanProperty] = []interface{}{
+			map[string]interface{}{"name": "6", "release": "redis-6"},
+			map[string]interface{}{"name": "7", "release": "redis-7", "default": true},
+			map[string]interface{}{"name": "valkey-8", "release": "valkey"},
+		}
+	})
+
+	withVersion := func(version string) map[string]interface{} {
+		return map[string]interface{}{"parameters": map[string]interface{}{adapter.RedisVersionParameter: version}}
+	}
+
+	redisServerRelease := func(manifest bosh.BoshManifest) string {
+		return manifest.InstanceGroups[0].Jobs[0].Release
+	}
+
+	It("deploys the default version, with only its release", func() {
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisServerRelease(output.Manifest)).To(Equal("redis-7"))
+		Expect(output.Manifest.InstanceGroups[1].Jobs[0].Release).To(Equal("redis-7"))
+		Expect(output.Manifest.Releases).To(Equal([]bosh.Release{
+			{Name: "redis-7", Version: "7.4"},
+			{Name: "syslog", Version: "12"},
+		}))
+	})
+
+	It("deploys the version users pick, and keeps it when updating", func() {
+		params.RequestParams = withVersion("6")
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisServerRelease(output.Manifest)).To(Equal("redis-6"))
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = nil
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisServerRelease(output.Manifest)).To(Equal("redis-6"))
+	})
+
+	It("upgrades instances across flavors", func() {
+		params.RequestParams = withVersion("7")
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = withVersion("valkey-8")
+		output, err = generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redisServerRelease(output.Manifest)).To(Equal("valkey"))
+	})
+
+	It("does not let users downgrade instances", func() {
+		params.RequestParams = withVersion("valkey-8")
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.PreviousManifest = &output.Manifest
+		params.RequestParams = withVersion("7")
+		_, err = generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.UserErrorKind, "cannot change redis_version from valkey-8 to 7: instances can only be upgraded"))
+	})
+
+	Describe("plan changes", func() {
+		BeforeEach(func() {
+			params.RequestParams = withVersion("valkey-8")
+			output, err := generator.GenerateManifest(params)
+			Expect(err).NotTo(HaveOccurred())
+
+			previousPlan := params.Plan
+			previousPlan.Properties = serviceadapter.Properties{}
+			for key, value := range params.Plan.Properties {
+				previousPlan.Properties[key] = value
+			}
+			params.PreviousPlan = &previousPlan
+			params.PreviousManifest = &output.Manifest
+			params.RequestParams = nil
+		})
+
+		It("do not downgrade instances to a plan that no longer lists their version", func() {
+			params.Plan.Properties[adapter.RedisVersionsPlanProperty] = []interface{}{
+				map[string]interface{}{"name": "6", "release": "redis-6"},
+				map[string]interface{}{"name": "7", "release": "redis-7", "default": true},
+			}
+			params.RequestParams = withVersion("7")
+
+			_, err := generator.GenerateManifest(params)
+			Expect(err).To(matchAdapterError(adapter.OperatorErrorKind,
+				"error generating manifest: cannot move the instance from version valkey-8 to 7: instances can only be upgraded"))
+		})
+
+		It("do not downgrade instances to a plan listing the versions in another order", func() {
+			params.ServiceDeployment.Releases = params.ServiceDeployment.Releases[1:]
+			params.Plan.Properties[adapter.RedisVersionsPlanProperty] = []interface{}{
+				map[string]interface{}{"name": "valkey-8", "release": "valkey"},
+				map[string]interface{}{"name": "7", "release": "redis-7", "default": true},
+			}
+			params.RequestParams = withVersion("7")
+
+			_, err := generator.GenerateManifest(params)
+			Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, ContainSubstring("cannot move the instance from version valkey-8 to 7")))
+		})
+	})
+
+	It("still rejects lower release versions of the same flavor", func() {
+		output, err := generator.GenerateManifest(params)
+		Expect(err).NotTo(HaveOccurred())
+
+		params.PreviousManifest = &output.Manifest
+		params.ServiceDeployment.Releases[1].Version = "7.2"
+		_, err = generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "error generating manifest: new release version 7.2 is lower than existing release version 7.4"))
+	})
+
+	Describe("instances running a release the plan does not list", func() {
+		var unlistedRelease serviceadapter.ServiceRelease
+
+		BeforeEach(func() {
+			unlistedRelease = serviceadapter.ServiceRelease{Name: "redis", Version: "5.0", Jobs: []string{"redis-server", "health-check"}}
+			versions := params.Plan.Properties[adapter.RedisVersionsPlanProperty]
+			releases := params.ServiceDeployment.Releases
+
+			delete(params.Plan.Properties, adapter.RedisVersionsPlanProperty)
+			params.ServiceDeployment.Releases = serviceadapter.ServiceReleases{unlistedRelease, releases[3]}
+			output, err := generator.GenerateManifest(params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(redisServerRelease(output.Manifest)).To(Equal("redis"))
+
+			params.PreviousManifest = &output.Manifest
+			params.Plan.Properties[adapter.RedisVersionsPlanProperty] = versions
+			params.ServiceDeployment.Releases = releases
+		})
+
+		It("keeps them on their release while it is deployed", func() {
+			params.ServiceDeployment.Releases = append(params.ServiceDeployment.Releases, unlistedRelease)
+			output, err := generator.GenerateManifest(params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(redisServerRelease(output.Manifest)).To(Equal("redis"))
+		})
+
+		It("moves them to any version users pick", func() {
+			params.ServiceDeployment.Releases = append(params.ServiceDeployment.Releases, unlistedRelease)
+			params.RequestParams = withVersion("6")
+			output, err := generator.GenerateManifest(params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(redisServerRelease(output.Manifest)).To(Equal("redis-6"))
+		})
+
+		It("does not move them to the default version once their release is gone", func() {
+			_, err := generator.GenerateManifest(params)
+			Expect(err).To(matchAdapterError(adapter.OperatorErrorKind,
+				"the instance runs release redis, which is neither one of the plan's redis_versions nor deployed any more; set redis_version to one of: 6, 7, valkey-8"))
+		})
+	})
+
+	It("rejects versions the plan does not offer", func() {
+		params.RequestParams = withVersion("5")
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.UserErrorKind, `unsupported redis_version "5" for this service plan, available versions are: 6, 7, valkey-8`))
+	})
+
+	It("reports every problem with the plan's versions to operators", func() {
+		params.Plan.Properties[adapter.RedisVersionsPlanProperty] = []interface{}{
+			map[string]interface{}{"name": "7", "release": "redis-7", "default": true},
+			map[string]interface{}{"name": "7", "release": "redis-7", "default": true},
+			map[string]interface{}{"name": "valkey-8"},
+		}
+
+		_, err := generator.GenerateManifest(params)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "invalid plan property redis_versions: "+
+			"version 7 is listed more than once; "+
+			"release redis-7 delivers more than one version; "+
+			"version 2 has no release; "+
+			"more than one version is marked default"))
+	})
+})
@@ -0,0 +1,50 @@
+package adapter
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// ReleaseLookups remembers which release provides each job, so generating
+// manifests for many instances of the same service deployment looks each job
+// up once. A nil *ReleaseLookups looks jobs up every time.
+type ReleaseLookups struct {
+	mu      sync.Mutex
+	lookups map[string]releaseLookup
+}
+
+type releaseLookup struct {
+	release serviceadapter.ServiceRelease
+	err     error
+}
+
+func NewReleaseLookups() *ReleaseLookups {
+	return &ReleaseLookups{lookups: map[string]releaseLookup{}}
+}
+
+func (l *ReleaseLookups) ReleaseForJob(job string, releases serviceadapter.ServiceReleases) (serviceadapter.ServiceRelease, error) {
+	if l == nil {
+		return findReleaseForJob(job, releases)
+	}
+
+	key := releasesKey(job, releases)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lookup, found := l.lookups[key]; found {
+		return lookup.release, lookup.err
+	}
+	release, err := findReleaseForJob(job, releases)
+	l.lookups[key] = releaseLookup{release: release, err: err}
+	return release, err
+}
+
+func releasesKey(job string, releases serviceadapter.ServiceReleases) string {
+	var key strings.Builder
+	key.WriteString(job)
+	for _, release := range releases {
+		key.WriteString("\x00" + release.Name + "/" + release.Version + ":" + strings.Join(release.Jobs, ","))
+	}
+	return key.String()
+}
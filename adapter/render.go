@@ -0,0 +1,172 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// RenderInputs are the files plan authors pass to the render subcommand
+// instead of the JSON arguments the on-demand broker sends.
+type RenderInputs struct {
+	PlanPath              string
+	ServiceDeploymentPath string
+	PreviousManifestPath  string
+	PreviousPlanPath      string
+	ParametersPath        string
+	PlanID                string
+}
+
+// RenderedManifest is what the render subcommand prints.
+type RenderedManifest struct {
+	Manifest          bosh.BoshManifest                `yaml:"manifest"`
+	ODBManagedSecrets serviceadapter.ODBManagedSecrets `yaml:"odb_managed_secrets,omitempty"`
+	Configs           serviceadapter.BOSHConfigs       `yaml:"configs,omitempty"`
+}
+
+// LoadRenderInputs reads the plan and service deployment, which are written
+// in YAML like in the broker's manifest, the optional previous manifest and
+// plan, and the optional arbitrary parameters as passed to
+// `cf create-service -c`.
+func LoadRenderInputs(inputs RenderInputs) (serviceadapter.GenerateManifestParams, error) {
+	params := serviceadapter.GenerateManifestParams{}
+
+	if err := readYAMLAsJSON(inputs.ServiceDeploymentPath, &params.ServiceDeployment); err != nil {
+		return params, fmt.Errorf("could not read service deployment: %s", err)
+	}
+	if err := params.ServiceDeployment.Validate(); err != nil {
+		return params, fmt.Errorf("invalid service deployment %s: %s", inputs.ServiceDeploymentPath, err)
+	}
+
+	plan, err := readPlan(inputs.PlanPath)
+	if err != nil {
+		return params, err
+	}
+	params.Plan = plan
+
+	if inputs.PreviousPlanPath != "" {
+		previousPlan := serviceadapter.Plan{}
+		if err := readYAMLAsJSON(inputs.PreviousPlanPath, &previousPlan); err != nil {
+			return params, fmt.Errorf("could not read previous plan: %s", err)
+		}
+		params.PreviousPlan = &previousPlan
+	}
+
+	if inputs.PreviousManifestPath != "" {
+		previousManifest, err := readManifest(inputs.PreviousManifestPath)
+		if err != nil {
+			return params, fmt.Errorf("could not read previous manifest: %s", err)
+		}
+		params.PreviousManifest = &previousManifest
+	}
+
+	requestParams, err := readRequestParameters(inputs.ParametersPath, inputs.PlanID)
+	if err != nil {
+		return params, err
+	}
+	params.RequestParams = requestParams
+
+	return params, nil
+}
+
+// Render generates the manifest for params and marshals it, together with the
+// secrets and configs ODB would store, to YAML.
+func Render(generator serviceadapter.ManifestGenerator, params serviceadapter.GenerateManifestParams) ([]byte, error) {
+	output, err := generator.GenerateManifest(params)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(RenderedManifest{
+		Manifest:          output.Manifest,
+		ODBManagedSecrets: output.ODBManagedSecrets,
+		Configs:           output.Configs,
+	})
+}
+
+func readPlan(path string) (serviceadapter.Plan, error) {
+	plan := serviceadapter.Plan{}
+	if err := readYAMLAsJSON(path, &plan); err != nil {
+		return plan, fmt.Errorf("could not read plan: %s", err)
+	}
+	if err := plan.Validate(); err != nil {
+		return plan, fmt.Errorf("invalid plan %s: %s", path, err)
+	}
+	return plan, nil
+}
+
+func readManifest(path string) (bosh.BoshManifest, error) {
+	manifest := bosh.BoshManifest{}
+	manifestBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return manifest, err
+	}
+	if err := yaml.Unmarshal(manifestBytes, &manifest); err != nil {
+		return manifest, fmt.Errorf("could not parse %s: %s", path, err)
+	}
+	return manifest, nil
+}
+
+// readRequestParameters wraps the arbitrary parameters in path, if any, the
+// way the broker passes them to generate-manifest.
+func readRequestParameters(path, planID string) (serviceadapter.RequestParameters, error) {
+	arbitraryParams := map[string]interface{}{}
+	if path != "" {
+		paramsBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read parameters: %s", err)
+		}
+		if err := json.Unmarshal(paramsBytes, &arbitraryParams); err != nil {
+			return nil, fmt.Errorf("could not parse parameters %s: %s", path, err)
+		}
+	}
+	requestParams := serviceadapter.RequestParameters{"parameters": arbitraryParams}
+	if planID != "" {
+		requestParams["plan_id"] = planID
+	}
+	return requestParams, nil
+}
+
+// readYAMLAsJSON decodes a YAML file into a type that, like the SDK's plan and
+// service deployment, only carries json tags.
+func readYAMLAsJSON(path string, target interface{}) error {
+	yamlBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(yamlBytes, &document); err != nil {
+		return fmt.Errorf("could not parse %s: %s", path, err)
+	}
+	jsonBytes, err := json.Marshal(jsonCompatible(document))
+	if err != nil {
+		return fmt.Errorf("could not convert %s: %s", path, err)
+	}
+	if err := json.Unmarshal(jsonBytes, target); err != nil {
+		return fmt.Errorf("could not decode %s: %s", path, err)
+	}
+	return nil
+}
+
+// jsonCompatible replaces the map[interface{}]interface{} values yaml.v2
+// produces with map[string]interface{}, which encoding/json can marshal.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			converted[fmt.Sprint(key)] = jsonCompatible(nested)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, nested := range v {
+			converted[i] = jsonCompatible(nested)
+		}
+		return converted
+	}
+	return value
+}
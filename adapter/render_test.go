@@ -0,0 +1,104 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Render", func() {
+	var (
+		inputs    adapter.RenderInputs
+		generator adapter.ManifestGenerator
+	)
+
+	BeforeEach(func() {
+		inputs = adapter.RenderInputs{
+			PlanPath:              getFixturePath("render/plan.yml"),
+			ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+			PlanID:                "some-plan",
+		}
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.ManifestGenerator{
+			Config:       config,
+			StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0),
+		}
+	})
+
+	It("loads the plan and service deployment from YAML", func() {
+		params, err := adapter.LoadRenderInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(params.ServiceDeployment.DeploymentName).To(Equal("service-instance_some-guid"))
+		Expect(params.ServiceDeployment.Releases[0].Jobs).To(ContainElement("health-check"))
+		Expect(params.ServiceDeployment.Stemcells).To(Equal([]serviceadapter.Stemcell{{OS: "ubuntu-jammy", Version: "1.1"}}))
+		Expect(params.Plan.Properties["persistence"]).To(BeTrue())
+		Expect(params.Plan.InstanceGroups[0].PersistentDiskType).To(Equal("dedicated-disk"))
+		Expect(params.Plan.InstanceGroups[1].Lifecycle).To(Equal("errand"))
+		Expect(params.Plan.Update.Canaries).To(Equal(1))
+		Expect(params.RequestParams).To(Equal(serviceadapter.RequestParameters{
+			"plan_id":    "some-plan",
+			"parameters": map[string]interface{}{},
+		}))
+		Expect(params.PreviousManifest).To(BeNil())
+	})
+
+	It("loads the parameters and previous manifest when given", func() {
+		inputs.ParametersPath = getFixturePath("render/parameters.json")
+		inputs.PreviousManifestPath = getFixturePath("render/previous-manifest.yml")
+
+		params, err := adapter.LoadRenderInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(params.RequestParams.ArbitraryParams()).To(Equal(map[string]interface{}{"maxclients": float64(100)}))
+		Expect(params.PreviousManifest).NotTo(BeNil())
+		Expect(params.PreviousManifest.InstanceGroups[0].Jobs[0].Name).To(Equal("redis-server"))
+	})
+
+	It("rejects a plan the broker would reject", func() {
+		inputs.PlanPath = getFixturePath("render/plan-missing-azs.yml")
+
+		_, err := adapter.LoadRenderInputs(inputs)
+		Expect(err).To(MatchError(ContainSubstring("invalid plan")))
+	})
+
+	It("fails when a file is missing", func() {
+		inputs.ParametersPath = getFixturePath("render/does-not-exist.json")
+
+		_, err := adapter.LoadRenderInputs(inputs)
+		Expect(err).To(MatchError(ContainSubstring("could not read parameters")))
+	})
+
+	It("prints the manifest, secrets and configs as YAML", func() {
+		inputs.ParametersPath = getFixturePath("render/parameters.json")
+		inputs.PreviousManifestPath = getFixturePath("render/previous-manifest.yml")
+		params, err := adapter.LoadRenderInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+
+		rendered, err := adapter.Render(generator, params)
+		Expect(err).NotTo(HaveOccurred())
+
+		var output adapter.RenderedManifest
+		Expect(yaml.Unmarshal(rendered, &output)).To(Succeed())
+		Expect(output.Manifest.Name).To(Equal("service-instance_some-guid"))
+		Expect(output.Manifest.InstanceGroups[0].Name).To(Equal("redis-server"))
+		Expect(string(rendered)).To(ContainSubstring("maxclients: 100"))
+		Expect(string(rendered)).To(ContainSubstring("odb_managed_secrets:"))
+	})
+
+	It("returns the generator's errors", func() {
+		inputs.ParametersPath = getFixturePath("render/parameters.json")
+		params, err := adapter.LoadRenderInputs(inputs)
+		Expect(err).NotTo(HaveOccurred())
+		params.RequestParams["parameters"] = map[string]interface{}{"foo": "bar"}
+
+		_, err = adapter.Render(generator, params)
+		Expect(adapter.ErrorKindOf(err)).To(Equal(adapter.UserErrorKind))
+	})
+})
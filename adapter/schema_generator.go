@@ -0,0 +1,130 @@
+package adapter
+
+import (
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-04/schema#"
+
+// knownParameterSchemas describe the parameters of the default service
+// definition. Other parameters a service definition or the hooks list accept
+// any value.
+var knownParameterSchemas = map[string]map[string]interface{}{
+	"maxclients": {
+		"type":        "integer",
+		"minimum":     1,
+		"description": "Maximum number of connected clients",
+	},
+	"credhub_secret_path": {
+		"type":        "string",
+		"description": "Path of a CredHub secret made available to the Redis server",
+	},
+}
+
+// SchemaGenerator describes the arbitrary parameters of each plan, so that
+// brokers can validate them and users can discover them.
+type SchemaGenerator struct {
+	Config Config
+	Hooks  Hooks
+}
+
+func (s SchemaGenerator) GeneratePlanSchema(params serviceadapter.GeneratePlanSchemaParams) (serviceadapter.PlanSchema, error) {
+	directives, err := RedisConfigDirectives(params.Plan.Properties)
+	if err != nil {
+		return serviceadapter.PlanSchema{}, NewOperatorError(err)
+	}
+	modules, err := RedisModules(params.Plan.Properties)
+	if err != nil {
+		return serviceadapter.PlanSchema{}, NewOperatorError(err)
+	}
+	versions, err := RedisVersions(params.Plan.Properties)
+	if err != nil {
+		return serviceadapter.PlanSchema{}, NewOperatorError(err)
+	}
+
+	properties := map[string]interface{}{}
+	parameters := append(append([]string{}, s.Config.ServiceDefinitionOrDefault().Parameters...), s.Hooks.Parameters...)
+	for _, parameter := range parameters {
+		if schema, known := knownParameterSchemas[parameter]; known {
+			properties[parameter] = schema
+		} else {
+			properties[parameter] = map[string]interface{}{}
+		}
+	}
+	if _, declared := params.Plan.Properties[RedisConfigDirectivesPlanProperty]; declared {
+		properties[RedisConfigParameter] = redisConfigSchema(directives)
+	}
+	if _, declared := params.Plan.Properties[RedisModulesPlanProperty]; declared {
+		properties[RedisModulesParameter] = map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string", "enum": sortedModuleNames(modules)},
+			"uniqueItems": true,
+			"description": "Redis modules to load",
+		}
+	}
+	if len(versions) > 0 {
+		properties[RedisVersionParameter] = map[string]interface{}{
+			"type":        "string",
+			"enum":        redisVersionNames(versions),
+			"description": "Redis server version; instances can only be upgraded",
+		}
+	}
+	instanceSchema := objectSchema(properties)
+
+	bindingSchema := objectSchema(map[string]interface{}{
+		CredentialsProfileParameter: map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{DefaultCredentialsProfile, SpringCredentialsProfile, URLCredentialsProfile},
+			"description": "Layout of the binding credentials",
+		},
+	})
+
+	return serviceadapter.PlanSchema{
+		ServiceInstance: serviceadapter.ServiceInstanceSchema{
+			Create: serviceadapter.JSONSchemas{Parameters: instanceSchema},
+			Update: serviceadapter.JSONSchemas{Parameters: instanceSchema},
+		},
+		ServiceBinding: serviceadapter.ServiceBindingSchema{
+			Create: serviceadapter.JSONSchemas{Parameters: bindingSchema},
+		},
+	}, nil
+}
+
+func redisConfigSchema(directives map[string]RedisConfigDirective) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for name, directive := range directives {
+		schema := map[string]interface{}{"type": directive.Type}
+		if directive.Description != "" {
+			schema["description"] = directive.Description
+		}
+		if len(directive.Values) > 0 {
+			schema["enum"] = directive.Values
+		}
+		if directive.Min != nil {
+			schema["minimum"] = *directive.Min
+		}
+		if directive.Max != nil {
+			schema["maximum"] = *directive.Max
+		}
+		if directive.Default != nil {
+			schema["default"] = directive.Default
+		}
+		properties[name] = schema
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+		"description":          "redis.conf directives",
+	}
+}
+
+// objectSchema allows additional properties, since the adapter accepts some
+// parameters, e.g. odb_managed_secret, that are not meant for users.
+func objectSchema(properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"$schema":    jsonSchemaDraft,
+		"type":       "object",
+		"properties": properties,
+	}
+}
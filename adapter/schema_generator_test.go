@@ -0,0 +1,115 @@
+package adapter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("SchemaGenerator", func() {
+	var generator adapter.SchemaGenerator
+
+	BeforeEach(func() {
+		config := adapter.Config{}
+		config.ApplyDefaults()
+		generator = adapter.SchemaGenerator{Config: config}
+	})
+
+	It("describes the service definition's parameters", func() {
+		schema, err := generator.GeneratePlanSchema(serviceadapter.GeneratePlanSchemaParams{})
+		Expect(err).NotTo(HaveOccurred())
+
+		parameters := schema.ServiceInstance.Create.Parameters
+		Expect(parameters).To(HaveKeyWithValue("$schema", "http://json-schema.org/draft-04/schema#"))
+		Expect(parameters["properties"]).To(HaveKey("maxclients"))
+		Expect(parameters["properties"]).To(HaveKey("credhub_secret_path"))
+		Expect(parameters["properties"]).NotTo(HaveKey(adapter.RedisConfigParameter))
+		Expect(schema.ServiceInstance.Update).To(Equal(schema.ServiceInstance.Create))
+		Expect(schema.ServiceBinding.Create.Parameters["properties"]).To(HaveKey(adapter.CredentialsProfileParameter))
+	})
+
+	It("describes the parameters the hooks accept", func() {
+		generator.Hooks.Parameters = []string{"team"}
+
+		schema, err := generator.GeneratePlanSchema(serviceadapter.GeneratePlanSchemaParams{})
+		Expect(err).NotTo(HaveOccurred())
+
+		properties := schema.ServiceInstance.Create.Parameters["properties"].(map[string]interface{})
+		Expect(properties).To(HaveKeyWithValue("team", map[string]interface{}{}))
+		Expect(properties).To(HaveKey("maxclients"))
+	})
+
+	It("describes the redis.conf directives the plan declares", func() {
+		schema, err := generator.GeneratePlanSchema(serviceadapter.GeneratePlanSchemaParams{
+			Plan: serviceadapter.Plan{Properties: serviceadapter.Properties{
+				adapter.RedisConfigDirectivesPlanProperty: map[interface{}]interface{}{
+					"maxmemory-policy": map[interface{}]interface{}{
+						"type":    "string",
+						"values":  []interface{}{"noeviction", "allkeys-lru"},
+						"default": "noeviction",
+					},
+					"timeout": map[interface{}]interface{}{"type": "integer", "min": 0, "max": 3600},
+				},
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		properties := schema.ServiceInstance.Create.Parameters["properties"].(map[string]interface{})
+		Expect(properties[adapter.RedisConfigParameter]).To(Equal(map[string]interface{}{
+			"type":                 "object",
+			"description":          "redis.conf directives",
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"maxmemory-policy": map[string]interface{}{
+					"type":    "string",
+					"enum":    []string{"noeviction", "allkeys-lru"},
+					"default": "noeviction",
+				},
+				"timeout": map[string]interface{}{"type": "integer", "minimum": int64(0), "maximum": int64(3600)},
+			},
+		}))
+	})
+
+	It("describes the modules the plan declares", func() {
+		schema, err := generator.GeneratePlanSchema(serviceadapter.GeneratePlanSchemaParams{
+			Plan: serviceadapter.Plan{Properties: serviceadapter.Properties{
+				adapter.RedisModulesPlanProperty: map[string]interface{}{
+					"json":  map[string]interface{}{"job": "redisjson", "path": "/var/vcap/packages/redisjson/librejson.so"},
+					"bloom": map[string]interface{}{"job": "redisbloom", "path": "/var/vcap/packages/redisbloom/redisbloom.so"},
+				},
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		properties := schema.ServiceInstance.Create.Parameters["properties"].(map[string]interface{})
+		Expect(properties[adapter.RedisModulesParameter]).To(HaveKeyWithValue("items", map[string]interface{}{
+			"type": "string",
+			"enum": []string{"bloom", "json"},
+		}))
+	})
+
+	It("lists the versions the plan offers", func() {
+		schema, err := generator.GeneratePlanSchema(serviceadapter.GeneratePlanSchemaParams{
+			Plan: serviceadapter.Plan{Properties: serviceadapter.Properties{
+				adapter.RedisVersionsPlanProperty: []interface{}{
+					map[interface{}]interface{}{"name": "7", "release": "redis-7"},
+					map[interface{}]interface{}{"name": "valkey-8", "release": "valkey"},
+				},
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		properties := schema.ServiceInstance.Create.Parameters["properties"].(map[string]interface{})
+		Expect(properties[adapter.RedisVersionParameter]).To(HaveKeyWithValue("enum", []string{"7", "valkey-8"}))
+	})
+
+	It("fails when the plan's declaration is invalid", func() {
+		_, err := generator.GeneratePlanSchema(serviceadapter.GeneratePlanSchemaParams{
+			Plan: serviceadapter.Plan{Properties: serviceadapter.Properties{
+				adapter.RedisConfigDirectivesPlanProperty: map[string]interface{}{"port": map[string]interface{}{"type": "integer"}},
+			}},
+		})
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, ContainSubstring("directive port is managed by the adapter")))
+	})
+})
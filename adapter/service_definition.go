@@ -0,0 +1,453 @@
+package adapter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ServiceDefinition describes the deployment the adapter generates: its
+// instance groups, their jobs and properties, and the variables BOSH
+// generates for it. The first instance group is the Redis server, which the
+// plan must define; it is the one bindings connect to.
+//
+// Property values may contain ${namespace.key} references, which are
+// replaced when generating manifests:
+//
+//	${parameters.key}  an arbitrary parameter passed when creating or updating
+//	                   the instance; it must be listed in parameters
+//	${plan.key}        a plan property
+//	${adapter.key}     a value the adapter works out for the Redis server, see
+//	                   AdapterTemplateValues
+//
+// A property whose references have no value is left out of the manifest.
+type ServiceDefinition struct {
+	Parameters     []string                  `yaml:"parameters,omitempty"`
+	InstanceGroups []InstanceGroupDefinition `yaml:"instance_groups"`
+	Variables      []bosh.Variable           `yaml:"variables,omitempty"`
+	Tags           map[string]interface{}    `yaml:"tags,omitempty"`
+}
+
+// InstanceGroupDefinition is deployed with the plan's instance group of the
+// same name. Optional instance groups are only deployed when the plan defines
+// them.
+type InstanceGroupDefinition struct {
+	Name      string          `yaml:"name"`
+	Lifecycle string          `yaml:"lifecycle,omitempty"`
+	Optional  bool            `yaml:"optional,omitempty"`
+	Jobs      []JobDefinition `yaml:"jobs"`
+}
+
+type JobDefinition struct {
+	Name                      string                          `yaml:"name"`
+	CustomProviderDefinitions []bosh.CustomProviderDefinition `yaml:"custom_provider_definitions,omitempty"`
+	SharedProvides            []string                        `yaml:"shared_provides,omitempty"`
+	Properties                map[string]interface{}          `yaml:"properties,omitempty"`
+}
+
+const (
+	ParametersTemplateNamespace = "parameters"
+	PlanTemplateNamespace       = "plan"
+	AdapterTemplateNamespace    = "adapter"
+)
+
+// AdapterTemplateValues are the ${adapter.key} values. Some only have a value
+// for some plans or instances, e.g. tls_port when the plan sets one, config
+// when the plan declares redis.conf directives users may set, or aclfile when
+// bindings are ACL users.
+var AdapterTemplateValues = []string{
+	"persistence",
+	"password",
+	"maxclients",
+	"odb_managed_secret",
+	"tls_port",
+	"service_instance_client",
+	"plan_secret",
+	"secret",
+	"config",
+	"rename_commands",
+	"denied_commands",
+	"default_user_denied_commands",
+	"aclfile",
+	"modules",
+	"loadmodule",
+}
+
+var templateReferenceRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+const defaultServiceDefinitionYAML = `
+parameters: [maxclients, credhub_secret_path]
+instance_groups:
+- name: redis-server
+  jobs:
+  - name: redis-server
+    custom_provider_definitions:
+    - name: redis-server-link
+      type: address
+    shared_provides: [redis]
+    properties:
+      redis:
+        persistence: ${adapter.persistence}
+        password: ${adapter.password}
+        maxclients: ${adapter.maxclients}
+        generated_secret: ((secret_pass))
+        odb_managed_secret: ${adapter.odb_managed_secret}
+        ca_cert: ((instance_certificate.ca))
+        certificate: ((instance_certificate.certificate))
+        private_key: ((instance_certificate.private_key))
+        tls_port: ${adapter.tls_port}
+        service_instance_client: ${adapter.service_instance_client}
+        plan_secret: ${adapter.plan_secret}
+        secret: ${adapter.secret}
+        config: ${adapter.config}
+        rename_commands: ${adapter.rename_commands}
+        denied_commands: ${adapter.denied_commands}
+        default_user_denied_commands: ${adapter.default_user_denied_commands}
+        aclfile: ${adapter.aclfile}
+        modules: ${adapter.modules}
+        loadmodule: ${adapter.loadmodule}
+- name: health-check
+  lifecycle: errand
+  optional: true
+  jobs:
+  - name: health-check
+- name: cleanup-data
+  lifecycle: errand
+  optional: true
+  jobs:
+  - name: cleanup-data
+variables:
+- name: secret_pass
+  type: password
+- name: instance_certificate
+  type: certificate
+  update_mode: no-overwrite
+  options:
+    is_ca: true
+    common_name: redis
+  consumes:
+    alternative_name:
+      from: redis-server-link
+      properties:
+        wildcard: true
+    common_name:
+      from: redis-server-link
+tags:
+  product: redis
+`
+
+var defaultServiceDefinition = parseDefaultServiceDefinition()
+
+func parseDefaultServiceDefinition() ServiceDefinition {
+	var definition ServiceDefinition
+	if err := yaml.UnmarshalStrict([]byte(defaultServiceDefinitionYAML), &definition); err != nil {
+		panic(fmt.Sprintf("invalid default service definition: %s", err))
+	}
+	return definition
+}
+
+// DefaultServiceDefinition is the Redis deployment this adapter has always
+// generated, with the Redis server instance group named instanceGroupName.
+func DefaultServiceDefinition(instanceGroupName string) ServiceDefinition {
+	definition := defaultServiceDefinition
+	definition.InstanceGroups = append([]InstanceGroupDefinition{}, defaultServiceDefinition.InstanceGroups...)
+	definition.InstanceGroups[0].Name = instanceGroupName
+	return definition
+}
+
+// ServiceDefinitionOrDefault returns the configured service definition, or the
+// default one when none is configured.
+func (c Config) ServiceDefinitionOrDefault() ServiceDefinition {
+	if c.ServiceDefinition != nil {
+		return *c.ServiceDefinition
+	}
+	return DefaultServiceDefinition(c.RedisInstanceGroupName)
+}
+
+// Validate reports every problem with the definition at once.
+func (d ServiceDefinition) Validate() error {
+	var problems []string
+
+	if len(d.InstanceGroups) == 0 {
+		problems = append(problems, "at least one instance group is required")
+	} else if d.InstanceGroups[0].Optional {
+		problems = append(problems, fmt.Sprintf("instance group %s must not be optional, as it is the Redis server", d.InstanceGroups[0].Name))
+	}
+
+	instanceGroupNames := map[string]bool{}
+	for _, instanceGroup := range d.InstanceGroups {
+		if strings.TrimSpace(instanceGroup.Name) == "" {
+			problems = append(problems, "instance group names must not be blank")
+			continue
+		}
+		if instanceGroupNames[instanceGroup.Name] {
+			problems = append(problems, fmt.Sprintf("instance group %s is defined more than once", instanceGroup.Name))
+		}
+		instanceGroupNames[instanceGroup.Name] = true
+		if instanceGroup.Lifecycle != "" {
+			problems = append(problems, checkOneOf(fmt.Sprintf("instance group %s lifecycle", instanceGroup.Name), instanceGroup.Lifecycle, "service", LifecycleErrandType)...)
+		}
+
+		if len(instanceGroup.Jobs) == 0 {
+			problems = append(problems, fmt.Sprintf("instance group %s has no jobs", instanceGroup.Name))
+		}
+		for _, job := range instanceGroup.Jobs {
+			if strings.TrimSpace(job.Name) == "" {
+				problems = append(problems, fmt.Sprintf("instance group %s has a job without a name", instanceGroup.Name))
+				continue
+			}
+			references := templateReferences(job.Properties)
+			sort.Strings(references)
+			for _, reference := range references {
+				if problem := d.referenceProblem(reference); problem != "" {
+					problems = append(problems, fmt.Sprintf("job %s property ${%s} %s", job.Name, reference, problem))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (d ServiceDefinition) referenceProblem(reference string) string {
+	parts := strings.SplitN(reference, ".", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "must look like ${namespace.key}"
+	}
+	namespace, key := parts[0], parts[1]
+	switch namespace {
+	case ParametersTemplateNamespace:
+		if !containsString(d.Parameters, strings.SplitN(key, ".", 2)[0]) {
+			return "refers to a parameter missing from parameters"
+		}
+	case PlanTemplateNamespace:
+	case AdapterTemplateNamespace:
+		if !containsString(AdapterTemplateValues, key) {
+			return fmt.Sprintf("is not an adapter value, which are %s", strings.Join(AdapterTemplateValues, ", "))
+		}
+	default:
+		return fmt.Sprintf("uses unknown namespace %q, expected %s, %s or %s", namespace, ParametersTemplateNamespace, PlanTemplateNamespace, AdapterTemplateNamespace)
+	}
+	return ""
+}
+
+func templateReferences(value interface{}) []string {
+	var references []string
+	switch v := value.(type) {
+	case string:
+		for _, match := range templateReferenceRegexp.FindAllStringSubmatch(v, -1) {
+			references = append(references, match[1])
+		}
+	case map[string]interface{}:
+		for _, nested := range v {
+			references = append(references, templateReferences(nested)...)
+		}
+	case map[interface{}]interface{}:
+		for _, nested := range v {
+			references = append(references, templateReferences(nested)...)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			references = append(references, templateReferences(nested)...)
+		}
+	}
+	return references
+}
+
+// templateValues resolves ${namespace.key} references.
+type templateValues struct {
+	parameters map[string]interface{}
+	plan       serviceadapter.Properties
+	adapter    map[string]interface{}
+}
+
+func (t templateValues) lookup(reference string) (interface{}, bool, error) {
+	parts := strings.SplitN(reference, ".", 2)
+	if len(parts) != 2 {
+		return nil, false, fmt.Errorf("template reference ${%s} must look like ${namespace.key}", reference)
+	}
+
+	var values map[string]interface{}
+	switch parts[0] {
+	case ParametersTemplateNamespace:
+		values = t.parameters
+	case PlanTemplateNamespace:
+		values = t.plan
+	case AdapterTemplateNamespace:
+		values = t.adapter
+	default:
+		return nil, false, fmt.Errorf("template reference ${%s} uses unknown namespace %q", reference, parts[0])
+	}
+
+	var value interface{} = values
+	for _, key := range strings.Split(parts[1], ".") {
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			value = nested[key]
+		case map[interface{}]interface{}:
+			value = nested[key]
+		default:
+			return nil, false, nil
+		}
+		if value == nil {
+			return nil, false, nil
+		}
+	}
+	return value, true, nil
+}
+
+// renderProperties replaces the references in properties, leaving out those
+// without a value.
+func (t templateValues) renderProperties(properties map[string]interface{}) (map[string]interface{}, error) {
+	if properties == nil {
+		return nil, nil
+	}
+	rendered := map[string]interface{}{}
+	for key, value := range properties {
+		renderedValue, found, err := t.render(value)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			rendered[key] = renderedValue
+		}
+	}
+	return rendered, nil
+}
+
+func (t templateValues) render(value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		return t.renderString(v)
+	case map[interface{}]interface{}:
+		rendered := map[interface{}]interface{}{}
+		for key, nested := range v {
+			renderedValue, found, err := t.render(nested)
+			if err != nil {
+				return nil, false, err
+			}
+			if found {
+				rendered[key] = renderedValue
+			}
+		}
+		return rendered, true, nil
+	case []interface{}:
+		rendered := []interface{}{}
+		for _, nested := range v {
+			renderedValue, found, err := t.render(nested)
+			if err != nil {
+				return nil, false, err
+			}
+			if found {
+				rendered = append(rendered, renderedValue)
+			}
+		}
+		return rendered, true, nil
+	}
+	return value, true, nil
+}
+
+// renderString keeps the type of values referenced on their own, so that
+// numbers and maps stay numbers and maps, and formats those embedded in text.
+func (t templateValues) renderString(text string) (interface{}, bool, error) {
+	matches := templateReferenceRegexp.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, true, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(text) {
+		return t.lookup(text[matches[0][2]:matches[0][3]])
+	}
+
+	var rendered strings.Builder
+	last := 0
+	for _, match := range matches {
+		value, found, err := t.lookup(text[match[2]:match[3]])
+		if err != nil || !found {
+			return nil, false, err
+		}
+		rendered.WriteString(text[last:match[0]])
+		rendered.WriteString(fmt.Sprint(value))
+		last = match[1]
+	}
+	rendered.WriteString(text[last:])
+	return rendered.String(), true, nil
+}
+
+// redisServerJobName is the job whose release decides which upgrades are
+// allowed.
+func (d ServiceDefinition) redisServerJobName() string {
+	if len(d.InstanceGroups) == 0 || len(d.InstanceGroups[0].Jobs) == 0 {
+		return RedisJobName
+	}
+	return d.InstanceGroups[0].Jobs[0].Name
+}
+
+// manifestTags copies the definition's tags, so that hooks customizing one
+// manifest change neither the config nor other manifests.
+func (d ServiceDefinition) manifestTags() map[string]interface{} {
+	tags := map[string]interface{}{}
+	for key, value := range d.Tags {
+		tags[key] = copyValue(value)
+	}
+	return tags
+}
+
+// manifestVariables copies the definition's variables, for the same reason as
+// manifestTags.
+func (d ServiceDefinition) manifestVariables() []bosh.Variable {
+	if d.Variables == nil {
+		return nil
+	}
+	variables := make([]bosh.Variable, len(d.Variables))
+	for i, variable := range d.Variables {
+		variable.Options = copyProperties(variable.Options)
+		if variable.Consumes != nil {
+			consumes := *variable.Consumes
+			consumes.AlternativeName.Properties = copyProperties(consumes.AlternativeName.Properties)
+			consumes.CommonName.Properties = copyProperties(consumes.CommonName.Properties)
+			variable.Consumes = &consumes
+		}
+		variables[i] = variable
+	}
+	return variables
+}
+
+func copyProperties(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+// copyValue deep copies the maps and lists of a value parsed from YAML.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyProperties(v)
+	case map[interface{}]interface{}:
+		copied := make(map[interface{}]interface{}, len(v))
+		for key, nested := range v {
+			copied[key] = copyValue(nested)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, nested := range v {
+			copied[i] = copyValue(nested)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+var _ = Describe("Service definitions", func() {
+	var (
+		generator adapter.ManifestGenerator
+		releases  serviceadapter.ServiceReleases
+		plan      serviceadapter.Plan
+	)
+
+	BeforeEach(func() {
+		stderrLogger := log.New(io.Writer(GinkgoWriter), "", 0)
+		config, err := adapter.LoadConfig(getFixturePath("config-service-definition.yml"), stderrLogger)
+		Expect(err).NotTo(HaveOccurred())
+		generator = adapter.ManifestGenerator{Config: config, StderrLogger: stderrLogger}
+
+		releases = serviceadapter.ServiceReleases{
+			{Name: "redis", Version: "4", Jobs: []string{"redis-server", "backup"}},
+			{Name: "syslog", Version: "11", Jobs: []string{"syslog-forwarder"}},
+		}
+		plan = serviceadapter.Plan{
+			Properties: serviceadapter.Properties{
+				"persistence":       true,
+				"max_memory_policy": "allkeys-lru",
+				"team":              "data",
+			},
+			InstanceGroups: []serviceadapter.InstanceGroup{{
+				Name:      "redis",
+				VMType:    "small",
+				Networks:  []string{"some-network"},
+				AZs:       []string{"some-az"},
+				Instances: 1,
+			}},
+		}
+	})
+
+	It("generates the instance groups, jobs and properties it describes", func() {
+		requestParams := map[string]interface{}{
+			"parameters": map[string]interface{}{"notify_keyspace_events": "Ex"},
+		}
+
+		generated, err := generateManifest(generator, releases, plan, requestParams, nil, nil, nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		manifest := generated.Manifest
+		Expect(manifest.InstanceGroups).To(HaveLen(1))
+		Expect(manifest.InstanceGroups[0].Name).To(Equal("redis"))
+		Expect(manifest.InstanceGroups[0].Jobs).To(HaveLen(2))
+
+		redisJob := manifest.InstanceGroups[0].Jobs[0]
+		Expect(redisJob.Release).To(Equal("redis"))
+		redisProperties := redisJob.Properties["redis"].(map[interface{}]interface{})
+		Expect(redisProperties["password"]).NotTo(BeEmpty())
+		delete(redisProperties, "password")
+		Expect(redisProperties).To(Equal(map[interface{}]interface{}{
+			"maxclients":             10000,
+			"persistence":            "yes",
+			"notify-keyspace-events": "Ex",
+			"max_memory_policy":      "allkeys-lru",
+			"banner":                 "Redis for data",
+		}))
+
+		Expect(manifest.InstanceGroups[0].Jobs[1]).To(Equal(bosh.Job{
+			Name:    "syslog-forwarder",
+			Release: "syslog",
+			Properties: map[string]interface{}{
+				"syslog": map[interface{}]interface{}{"address": "syslog.example.com"},
+			},
+		}))
+		Expect(manifest.Variables).To(Equal([]bosh.Variable{{Name: "secret_pass", Type: "password"}}))
+		Expect(manifest.Tags).To(Equal(map[string]interface{}{"product": "redis", "team": "data"}))
+	})
+
+	It("leaves out properties whose references have no value", func() {
+		delete(plan.Properties, "team")
+
+		generated, err := generateManifest(generator, releases, plan, map[string]interface{}{}, nil, nil, nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		redisProperties := generated.Manifest.InstanceGroups[0].Jobs[0].Properties["redis"]
+		Expect(redisProperties).NotTo(HaveKey("notify-keyspace-events"))
+		Expect(redisProperties).NotTo(HaveKey("banner"))
+		Expect(redisProperties).To(HaveKeyWithValue("max_memory_policy", "allkeys-lru"))
+	})
+
+	It("deploys optional instance groups the plan defines", func() {
+		plan.InstanceGroups = append(plan.InstanceGroups, serviceadapter.InstanceGroup{
+			Name:      "backup",
+			VMType:    "small",
+			Lifecycle: adapter.LifecycleErrandType,
+			Networks:  []string{"some-network"},
+			AZs:       []string{"some-az"},
+			Instances: 1,
+		})
+
+		generated, err := generateManifest(generator, releases, plan, map[string]interface{}{}, nil, nil, nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(generated.Manifest.InstanceGroups).To(HaveLen(2))
+		Expect(generated.Manifest.InstanceGroups[1].Name).To(Equal("backup"))
+		Expect(generated.Manifest.InstanceGroups[1].Lifecycle).To(Equal(adapter.LifecycleErrandType))
+	})
+
+	It("fails when the plan lacks an instance group that is not optional", func() {
+		generator.Config.ServiceDefinition.InstanceGroups = append(generator.Config.ServiceDefinition.InstanceGroups,
+			adapter.InstanceGroupDefinition{Name: "sentinel", Jobs: []adapter.JobDefinition{{Name: "redis-server"}}})
+
+		_, err := generateManifest(generator, releases, plan, map[string]interface{}{}, nil, nil, nil, nil, nil)
+		Expect(err).To(matchAdapterError(adapter.OperatorErrorKind, "no sentinel instance group definition found"))
+	})
+
+	It("gives each manifest its own tags and variables", func() {
+		generator.Config.ServiceDefinition.Tags = nil
+		generator.Hooks.ManifestCustomizers = []adapter.ManifestCustomizer{
+			adapter.ManifestCustomizerFunc(func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+				output.Manifest.Tags["team"] = "data"
+				output.Manifest.Variables[0].Name = "other_pass"
+				return nil
+			}),
+		}
+
+		for i := 0; i < 2; i++ {
+			generated, err := generateManifest(generator, releases, plan, map[string]interface{}{}, nil, nil, nil, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(generated.Manifest.Tags).To(Equal(map[string]interface{}{"team": "data"}))
+		}
+		Expect(generator.Config.ServiceDefinition.Tags).To(BeNil())
+		Expect(generator.Config.ServiceDefinition.Variables[0].Name).To(Equal("secret_pass"))
+	})
+
+	It("only accepts the parameters it lists", func() {
+		requestParams := map[string]interface{}{
+			"parameters": map[string]interface{}{"credhub_secret_path": "/some/path"},
+		}
+
+		_, err := generateManifest(generator, releases, plan, requestParams, nil, nil, nil, nil, nil)
+		Expect(err).To(matchAdapterError(adapter.UserErrorKind, "unsupported parameter(s) for this service plan: credhub_secret_path"))
+	})
+
+	It("accepts the default definition", func() {
+		Expect(adapter.DefaultServiceDefinition("redis-server").Validate()).To(Succeed())
+	})
+})
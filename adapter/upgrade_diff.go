@@ -0,0 +1,295 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	ManifestValueAdded   = "+"
+	ManifestValueRemoved = "-"
+	ManifestValueChanged = "~"
+)
+
+// ManifestDiffEntry is one value that differs between two manifests. Path
+// addresses the value with list items keyed by name, e.g.
+// instance_groups[redis-server].jobs[redis-server].properties.redis.maxclients.
+type ManifestDiffEntry struct {
+	Path   string
+	Change string
+	From   string
+	To     string
+	Risk   string
+}
+
+type ManifestDiff []ManifestDiffEntry
+
+func (d ManifestDiff) Risky() ManifestDiff {
+	var risky ManifestDiff
+	for _, entry := range d {
+		if entry.Risk != "" {
+			risky = append(risky, entry)
+		}
+	}
+	return risky
+}
+
+// String renders the diff for operators, one line per value, with risky
+// changes marked by a leading "!" and followed by the reason.
+func (d ManifestDiff) String() string {
+	if len(d) == 0 {
+		return "no changes\n"
+	}
+	var text strings.Builder
+	d.writeEntries(&text)
+	fmt.Fprintf(&text, "%d changes, %d risky\n", len(d), len(d.Risky()))
+	return text.String()
+}
+
+func (d ManifestDiff) writeEntries(text *strings.Builder) {
+	for _, entry := range d {
+		marker := " "
+		if entry.Risk != "" {
+			marker = "!"
+		}
+		switch entry.Change {
+		case ManifestValueAdded:
+			fmt.Fprintf(text, "%s + %s: %s\n", marker, entry.Path, entry.To)
+		case ManifestValueRemoved:
+			fmt.Fprintf(text, "%s - %s: %s\n", marker, entry.Path, entry.From)
+		default:
+			fmt.Fprintf(text, "%s ~ %s: %s -> %s\n", marker, entry.Path, entry.From, entry.To)
+		}
+		if entry.Risk != "" {
+			fmt.Fprintf(text, "      risk: %s\n", entry.Risk)
+		}
+	}
+}
+
+// ExplainUpgrade generates the manifest for params and compares it to
+// params.PreviousManifest, which is what `upgrade-all-service-instances` would
+// deploy for that instance.
+func ExplainUpgrade(generator serviceadapter.ManifestGenerator, params serviceadapter.GenerateManifestParams) (ManifestDiff, error) {
+	if params.PreviousManifest == nil {
+		return nil, errors.New("a previous manifest is required to explain an upgrade")
+	}
+	output, err := generator.GenerateManifest(params)
+	if err != nil {
+		return nil, err
+	}
+	return DiffManifests(*params.PreviousManifest, output.Manifest)
+}
+
+// DiffManifests lists every value that differs between previous and current.
+func DiffManifests(previous, current bosh.BoshManifest) (ManifestDiff, error) {
+	previousValues, err := flattenManifest(previous)
+	if err != nil {
+		return nil, fmt.Errorf("could not read previous manifest: %s", err)
+	}
+	currentValues, err := flattenManifest(current)
+	if err != nil {
+		return nil, fmt.Errorf("could not read generated manifest: %s", err)
+	}
+
+	diff := diffValues(previousValues, currentValues)
+	for i := range diff {
+		diff[i].Risk = upgradeRisk(diff[i])
+		if isSecretPath(diff[i].Path) {
+			diff[i].From, diff[i].To = redactDiffValue(diff[i].From), redactDiffValue(diff[i].To)
+		}
+	}
+	return diff, nil
+}
+
+// diffValues compares two sets of flattened values, sorted by path.
+func diffValues(previousValues, currentValues map[string]string) ManifestDiff {
+	var diff ManifestDiff
+	for path, from := range previousValues {
+		to, found := currentValues[path]
+		switch {
+		case !found:
+			diff = append(diff, ManifestDiffEntry{Path: path, Change: ManifestValueRemoved, From: from})
+		case from != to:
+			diff = append(diff, ManifestDiffEntry{Path: path, Change: ManifestValueChanged, From: from, To: to})
+		}
+	}
+	for path, to := range currentValues {
+		if _, found := previousValues[path]; !found {
+			diff = append(diff, ManifestDiffEntry{Path: path, Change: ManifestValueAdded, To: to})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+	return diff
+}
+
+var upgradeRisks = []struct {
+	matches func(entry ManifestDiffEntry, key string) bool
+	reason  string
+}{
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return key == "password" && strings.Contains(entry.Path, ".properties.") && entry.Change != ManifestValueAdded
+		},
+		reason: "the Redis password changes, existing bindings stop working until apps are rebound",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return key == "persistent_disk_type"
+		},
+		reason: "the persistent disk is replaced and its data copied, or dropped if the disk is removed",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return key == "persistence" && entry.To == "no"
+		},
+		reason: "Redis stops persisting data to disk",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return key == "instances" && entry.Change == ManifestValueChanged && atoi(entry.To) < atoi(entry.From)
+		},
+		reason: "instances are deleted together with their persistent disks",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return strings.HasPrefix(entry.Path, "instance_groups[") && key == "name" && entry.Change == ManifestValueRemoved
+		},
+		reason: "the instance group is deleted together with its persistent disks",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			moved := key == "azs" || (strings.Contains(entry.Path, ".networks[") && key == "name")
+			return moved && entry.Change != ManifestValueAdded
+		},
+		reason: "instances move, so their addresses change and bindings using IPs break",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return strings.HasPrefix(entry.Path, "stemcells[") && key == "os"
+		},
+		reason: "every VM is recreated on a different operating system",
+	},
+	{
+		matches: func(entry ManifestDiffEntry, key string) bool {
+			return strings.HasPrefix(entry.Path, "variables[") && key == "type" && entry.Change != ManifestValueAdded
+		},
+		reason: "BOSH regenerates the variable, replacing the current credential",
+	},
+}
+
+func upgradeRisk(entry ManifestDiffEntry) string {
+	key := entry.Path[strings.LastIndexAny(entry.Path, ".]")+1:]
+	for _, risk := range upgradeRisks {
+		if risk.matches(entry, key) {
+			return risk.reason
+		}
+	}
+	return ""
+}
+
+func isSecretPath(path string) bool {
+	key := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	for _, secretKey := range secretParameterKeys {
+		if strings.Contains(key, secretKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactDiffValue hides secret values, but keeps CredHub references, which
+// tell operators where the value comes from.
+func redactDiffValue(value string) string {
+	if value == "" || (strings.HasPrefix(value, "((") && strings.HasSuffix(value, "))")) {
+		return value
+	}
+	return redactedValue
+}
+
+// flattenManifest maps the path of every scalar value in manifest to its
+// YAML representation.
+func flattenManifest(manifest bosh.BoshManifest) (map[string]string, error) {
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(manifestBytes, &document); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	flattenValue("", document, values)
+	return values, nil
+}
+
+func flattenValue(path string, value interface{}, values map[string]string) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for key, nested := range v {
+			nestedPath := fmt.Sprint(key)
+			if path != "" {
+				nestedPath = path + "." + nestedPath
+			}
+			flattenValue(nestedPath, nested, values)
+		}
+	case []interface{}:
+		if !namedItems(v) {
+			values[path] = scalarList(v)
+			return
+		}
+		for _, item := range v {
+			flattenValue(fmt.Sprintf("%s[%s]", path, itemName(item)), item, values)
+		}
+	case nil:
+	default:
+		values[path] = fmt.Sprint(v)
+	}
+}
+
+// namedItems reports whether items are maps identified by a name or, for
+// stemcells, an alias, so that they can be matched up regardless of order.
+func namedItems(items []interface{}) bool {
+	if len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if itemName(item) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func itemName(item interface{}) string {
+	fields, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return ""
+	}
+	for _, key := range []string{"name", "alias"} {
+		if name, ok := fields[key].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func scalarList(items []interface{}) string {
+	itemsBytes, err := json.Marshal(jsonCompatible(items))
+	if err != nil {
+		return fmt.Sprint(items)
+	}
+	return string(itemsBytes)
+}
+
+func atoi(value string) int {
+	i, _ := strconv.Atoi(value)
+	return i
+}
@@ -0,0 +1,153 @@
+package adapter_test
+
+import (
+	"io"
+	"log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/bosh"
+)
+
+var _ = Describe("Upgrade diffs", func() {
+	var previous, current bosh.BoshManifest
+
+	manifest := func(releaseVersion string, instances int, diskType, password string) bosh.BoshManifest {
+		return bosh.BoshManifest{
+			Name:      "some-deployment",
+			Releases:  []bosh.Release{{Name: "redis", Version: releaseVersion}},
+			Stemcells: []bosh.Stemcell{{Alias: "only-stemcell", OS: "ubuntu-jammy", Version: "1.1"}},
+			InstanceGroups: []bosh.InstanceGroup{{
+				Name:               "redis-server",
+				Instances:          instances,
+				VMType:             "some-vm",
+				VMExtensions:       []string{"public-ip"},
+				PersistentDiskType: diskType,
+				AZs:                []string{"z1"},
+				Networks:           []bosh.Network{{Name: "some-network"}},
+				Jobs: []bosh.Job{{
+					Name:    "redis-server",
+					Release: "redis",
+					Properties: map[string]interface{}{
+						"redis": map[interface{}]interface{}{"password": password, "maxclients": 10},
+					},
+				}},
+			}},
+			Update: &bosh.Update{Canaries: 1, MaxInFlight: 1},
+		}
+	}
+
+	BeforeEach(func() {
+		previous = manifest("4", 3, "small", "old-password")
+		current = manifest("4", 3, "small", "old-password")
+	})
+
+	It("reports no changes for identical manifests", func() {
+		diff, err := adapter.DiffManifests(previous, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).To(BeEmpty())
+		Expect(diff.String()).To(Equal("no changes\n"))
+	})
+
+	It("lists changed, added and removed values keyed by name", func() {
+		current.Releases[0].Version = "5"
+		current.InstanceGroups[0].VMExtensions = []string{"public-ip", "lb"}
+		current.Update.Canaries = 2
+		current.Variables = []bosh.Variable{{Name: "secret_pass", Type: "password"}}
+		current.InstanceGroups[0].Jobs[0].Properties["redis"] = map[interface{}]interface{}{"password": "old-password"}
+
+		diff, err := adapter.DiffManifests(previous, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).To(ConsistOf(
+			adapter.ManifestDiffEntry{Path: "instance_groups[redis-server].jobs[redis-server].properties.redis.maxclients", Change: "-", From: "10"},
+			adapter.ManifestDiffEntry{Path: "instance_groups[redis-server].vm_extensions", Change: "~", From: `["public-ip"]`, To: `["public-ip","lb"]`},
+			adapter.ManifestDiffEntry{Path: "releases[redis].version", Change: "~", From: "4", To: "5"},
+			adapter.ManifestDiffEntry{Path: "update.canaries", Change: "~", From: "1", To: "2"},
+			adapter.ManifestDiffEntry{Path: "variables[secret_pass].name", Change: "+", To: "secret_pass"},
+			adapter.ManifestDiffEntry{Path: "variables[secret_pass].type", Change: "+", To: "password"},
+		))
+		Expect(diff.Risky()).To(BeEmpty())
+	})
+
+	It("flags risky changes without showing secrets", func() {
+		current = manifest("4", 1, "large", "new-password")
+
+		diff, err := adapter.DiffManifests(previous, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff.Risky()).To(HaveLen(3))
+
+		text := diff.String()
+		Expect(text).To(ContainSubstring("! ~ instance_groups[redis-server].instances: 3 -> 1\n      risk: instances are deleted"))
+		Expect(text).To(ContainSubstring("! ~ instance_groups[redis-server].persistent_disk_type: small -> large\n      risk: the persistent disk is replaced"))
+		Expect(text).To(ContainSubstring("! ~ instance_groups[redis-server].jobs[redis-server].properties.redis.password: <redacted> -> <redacted>\n      risk: the Redis password changes"))
+		Expect(text).NotTo(ContainSubstring("new-password"))
+		Expect(text).To(HaveSuffix("3 changes, 3 risky\n"))
+	})
+
+	It("flags moving instances to other AZs", func() {
+		current.InstanceGroups[0].AZs = []string{"z2"}
+
+		diff, err := adapter.DiffManifests(previous, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).To(HaveLen(1))
+		Expect(diff[0].Risk).To(ContainSubstring("addresses change"))
+	})
+
+	It("does not flag the placement of new instance groups", func() {
+		current.InstanceGroups = append(current.InstanceGroups, bosh.InstanceGroup{
+			Name:      "health-check",
+			Lifecycle: "errand",
+			Instances: 1,
+			AZs:       []string{"z1"},
+			Networks:  []bosh.Network{{Name: "some-network"}},
+		})
+
+		diff, err := adapter.DiffManifests(previous, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).NotTo(BeEmpty())
+		Expect(diff.Risky()).To(BeEmpty())
+	})
+
+	Describe("ExplainUpgrade", func() {
+		var generator adapter.ManifestGenerator
+
+		BeforeEach(func() {
+			config := adapter.Config{}
+			config.ApplyDefaults()
+			generator = adapter.ManifestGenerator{Config: config, StderrLogger: log.New(io.Writer(GinkgoWriter), "", 0)}
+		})
+
+		It("compares the generated manifest to the previous one", func() {
+			params, err := adapter.LoadRenderInputs(adapter.RenderInputs{
+				PlanPath:              getFixturePath("render/plan.yml"),
+				ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+				PreviousManifestPath:  getFixturePath("render/previous-manifest.yml"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			diff, err := adapter.ExplainUpgrade(generator, params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(ContainElement(adapter.ManifestDiffEntry{
+				Path:   "instance_groups[redis-server].persistent_disk_type",
+				Change: "+",
+				To:     "dedicated-disk",
+				Risk:   "the persistent disk is replaced and its data copied, or dropped if the disk is removed",
+			}))
+			for _, entry := range diff {
+				Expect(entry.Path).NotTo(HaveSuffix(".redis.password"))
+			}
+		})
+
+		It("requires a previous manifest", func() {
+			params, err := adapter.LoadRenderInputs(adapter.RenderInputs{
+				PlanPath:              getFixturePath("render/plan.yml"),
+				ServiceDeploymentPath: getFixturePath("render/service-deployment.yml"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = adapter.ExplainUpgrade(generator, params)
+			Expect(err).To(MatchError(ContainSubstring("previous manifest is required")))
+		})
+	})
+})
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+func runBatch(manifestGenerator adapter.ManifestGenerator, stderrLogger *log.Logger) int {
+	manifestGenerator.ReleaseLookups = adapter.NewReleaseLookups()
+
+	failed, err := adapter.RunBatch(manifestGenerator, os.Stdin, os.Stdout)
+	if err != nil {
+		stderrLogger.Printf("batch: %s", err)
+		return 1
+	}
+	if failed > 0 {
+		stderrLogger.Printf("batch: %d inputs failed", failed)
+		return 1
+	}
+	return 0
+}
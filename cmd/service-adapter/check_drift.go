@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+// driftExitCode is returned when drift was found, so scripts checking a fleet
+// can tell drifted instances from failures.
+const driftExitCode = 2
+
+func runCheckDrift(args []string, manifestGenerator adapter.ManifestGenerator, stderrLogger *log.Logger) int {
+	flags := flag.NewFlagSet("check-drift", flag.ContinueOnError)
+	inputs := adapter.DriftInputs{}
+	flags.StringVar(&inputs.DeployedManifestPath, "manifest", "", "deployed manifest, e.g. from bosh -d <deployment> manifest")
+	flags.StringVar(&inputs.PlanPath, "plan", "", "plan YAML the instance was deployed with")
+	flags.StringVar(&inputs.ParametersPath, "params", "", "JSON file with the arbitrary parameters the instance was configured with")
+	flags.StringVar(&inputs.PlanID, "plan-id", "", "plan ID passed in the request parameters")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if inputs.DeployedManifestPath == "" || inputs.PlanPath == "" {
+		stderrLogger.Println("check-drift: -manifest and -plan are required")
+		return 1
+	}
+
+	deployed, params, err := adapter.LoadDriftInputs(inputs)
+	if err != nil {
+		stderrLogger.Printf("check-drift: %s", err)
+		return 1
+	}
+
+	drift, err := adapter.CheckDrift(manifestGenerator, deployed, params)
+	if err != nil {
+		stderrLogger.Printf("check-drift: %s", adapter.ErrorDetail(err))
+		return 1
+	}
+	fmt.Print(drift.String())
+	if len(drift) > 0 {
+		return driftExitCode
+	}
+	return 0
+}
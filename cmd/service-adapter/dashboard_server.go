@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+func runDashboardServer(args []string, config adapter.Config, stderrLogger *log.Logger) int {
+	flags := flag.NewFlagSet("dashboard-server", flag.ContinueOnError)
+	listenAddress := flags.String("listen", ":8080", "address to listen on")
+	manifestsDir := flags.String("manifests-dir", "", "directory containing <instance-id>.yml manifests")
+	uaaURL := flags.String("uaa-url", config.DashboardUAAURL, "UAA used to check dashboard tokens")
+	noAuth := flags.Bool("no-auth", false, "disable authentication, for local fixtures only")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if *manifestsDir == "" {
+		stderrLogger.Println("dashboard-server: -manifests-dir is required")
+		return 1
+	}
+
+	var authenticator adapter.DashboardAuthenticator = adapter.UAATokenAuthenticator{UAAURL: *uaaURL}
+	if *noAuth {
+		stderrLogger.Println("dashboard-server: authentication disabled")
+		authenticator = adapter.NoAuthAuthenticator{}
+	} else if *uaaURL == "" {
+		stderrLogger.Println("dashboard-server: -uaa-url or dashboard_uaa_url is required unless -no-auth is set")
+		return 1
+	}
+
+	server := adapter.DashboardServer{
+		Manifests:     adapter.DirectoryManifestSource{Dir: *manifestsDir},
+		Authenticator: authenticator,
+		StderrLogger:  stderrLogger,
+	}
+
+	stderrLogger.Printf("dashboard-server: listening on %s", *listenAddress)
+	if err := http.ListenAndServe(*listenAddress, server); err != nil {
+		stderrLogger.Printf("dashboard-server: %s", err)
+		return 1
+	}
+	return 0
+}
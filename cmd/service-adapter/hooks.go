@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+// newHooks returns the hooks customizing this adapter. Register them here
+// rather than changing the adapter package, e.g.
+//
+//	hooks.ManifestCustomizers = append(hooks.ManifestCustomizers, adapter.ManifestCustomizerFunc(
+//		func(params serviceadapter.GenerateManifestParams, output *serviceadapter.GenerateManifestOutput) error {
+//			output.Manifest.Tags["team"] = "data"
+//			return nil
+//		},
+//	))
+func newHooks() adapter.Hooks {
+	hooks := adapter.Hooks{}
+	return hooks
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"os"
 
 	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
@@ -11,30 +10,88 @@ import (
 const ConfigPath = "/var/vcap/jobs/service-adapter/config/service-adapter.conf"
 
 func main() {
-	stderrLogger := log.New(os.Stderr, "[redis-service-adapter] ", log.LstdFlags)
+	stderrLogger := adapter.NewLogger(os.Stderr, adapter.JSONLogFormat, adapter.InfoLogLevel)
 
-	config, err := adapter.LoadConfig(ConfigPath, stderrLogger)
+	config, err := adapter.LoadConfigWithEnv(adapter.ConfigPath(os.Environ(), ConfigPath), os.Environ(), stderrLogger)
 	if err != nil {
 		os.Exit(serviceadapter.ErrorExitCode)
 	}
+	stderrLogger = adapter.NewLogger(os.Stderr, config.LogFormat, config.LogLevel)
+
+	if len(os.Args) > 1 && os.Args[1] == "print-config" {
+		os.Exit(printConfig(config, stderrLogger))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], stderrLogger))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "dashboard-server" {
+		os.Exit(runDashboardServer(os.Args[2:], config, stderrLogger))
+	}
+
+	var auditLog adapter.AuditLog
+	if config.AuditLogPath != "" {
+		auditLog = adapter.FileAuditLog{
+			Path:      config.AuditLogPath,
+			MaxSizeMB: config.AuditLogMaxSizeMB,
+			MaxFiles:  config.AuditLogMaxFiles,
+		}
+	}
+
+	hooks := newHooks()
 
 	manifestGenerator := adapter.ManifestGenerator{
 		StderrLogger: stderrLogger,
 		Config:       config,
+		AuditLog:     auditLog,
+		Hooks:        hooks,
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		os.Exit(runBatch(manifestGenerator, stderrLogger))
+	}
+
+	if len(os.Args) > 1 {
+		// offline renders do not change any deployment, so are not audited
+		offlineGenerator := manifestGenerator
+		offlineGenerator.AuditLog = nil
+		switch os.Args[1] {
+		case "render":
+			os.Exit(runRender(os.Args[2:], offlineGenerator, stderrLogger))
+		case "explain-upgrade":
+			os.Exit(runExplainUpgrade(os.Args[2:], offlineGenerator, stderrLogger))
+		case "check-drift":
+			os.Exit(runCheckDrift(os.Args[2:], offlineGenerator, stderrLogger))
+		}
 	}
 
 	binder := adapter.Binder{
 		StderrLogger: stderrLogger,
 		Config:       config,
+		AuditLog:     auditLog,
+		Hooks:        hooks,
+	}
+	if config.ACLBindingsEnabled {
+		binder.Bindings = adapter.ACLBindingRegistry{Retries: 2}
+	}
+	if config.BindingCredentialsMode == adapter.CredHubRefCredentialsMode {
+		binder.CredentialStore = adapter.CredHubStore{Config: config.CredHub}
 	}
 
-	dashboardGenerator := adapter.DashboardGenerator{}
+	dashboardGenerator := adapter.DashboardGenerator{
+		Config: config,
+	}
 
 	handler := serviceadapter.CommandLineHandler{
 		ManifestGenerator:     manifestGenerator,
 		Binder:                binder,
 		DashboardURLGenerator: dashboardGenerator,
+		SchemaGenerator:       adapter.SchemaGenerator{Config: config, Hooks: hooks},
 	}
 
-	serviceadapter.HandleCLI(os.Args, handler)
+	if config.RecordingDir != "" {
+		os.Exit(runRecorded(handler, config.RecordingDir, stderrLogger))
+	}
+	os.Exit(handle(handler, os.Stdout, os.Stdin))
 }
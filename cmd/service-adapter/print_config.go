@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+func printConfig(config adapter.Config, stderrLogger *log.Logger) int {
+	configYAML, err := config.RedactedYAML()
+	if err != nil {
+		stderrLogger.Printf("print-config: %s", err)
+		return 1
+	}
+	fmt.Print(string(configYAML))
+	return 0
+}
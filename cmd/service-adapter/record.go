@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+// runRecorded handles the invocation like handle, and saves what it was given
+// and what it printed to recordingDir so that it can be replayed.
+func runRecorded(handler serviceadapter.CommandLineHandler, recordingDir string, stderrLogger *log.Logger) int {
+	// the SDK only reads input params from stdin when there are no positional
+	// arguments
+	var stdin []byte
+	if len(os.Args) == 2 {
+		var err error
+		stdin, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			stderrLogger.Printf("could not read stdin: %s", err)
+			return serviceadapter.ErrorExitCode
+		}
+	}
+
+	stdout := &bytes.Buffer{}
+	exitCode := handle(handler, io.MultiWriter(os.Stdout, stdout), bytes.NewReader(stdin))
+
+	recorder := adapter.Recorder{Dir: recordingDir}
+	if _, err := recorder.Save(adapter.Recording{
+		Args:     os.Args[1:],
+		Stdin:    string(stdin),
+		Stdout:   stdout.String(),
+		ExitCode: exitCode,
+	}); err != nil {
+		stderrLogger.Printf("could not save recording: %s", err)
+	}
+	return exitCode
+}
+
+func handle(handler serviceadapter.CommandLineHandler, stdout io.Writer, stdin io.Reader) int {
+	if err := handler.Handle(os.Args, stdout, os.Stderr, stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "[odb-sdk] %s\n", err)
+		return adapter.ExitCode(err)
+	}
+	return 0
+}
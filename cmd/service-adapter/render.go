@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+	"github.com/pivotal-cf/on-demand-services-sdk/serviceadapter"
+)
+
+func runRender(args []string, manifestGenerator adapter.ManifestGenerator, stderrLogger *log.Logger) int {
+	params, ok := loadRenderInputs("render", args, stderrLogger)
+	if !ok {
+		return 1
+	}
+
+	rendered, err := adapter.Render(manifestGenerator, params)
+	if err != nil {
+		stderrLogger.Printf("render: %s", adapter.ErrorDetail(err))
+		return 1
+	}
+	fmt.Print(string(rendered))
+	return 0
+}
+
+func runExplainUpgrade(args []string, manifestGenerator adapter.ManifestGenerator, stderrLogger *log.Logger) int {
+	params, ok := loadRenderInputs("explain-upgrade", args, stderrLogger)
+	if !ok {
+		return 1
+	}
+	if params.PreviousManifest == nil {
+		stderrLogger.Println("explain-upgrade: -previous-manifest is required")
+		return 1
+	}
+
+	diff, err := adapter.ExplainUpgrade(manifestGenerator, params)
+	if err != nil {
+		stderrLogger.Printf("explain-upgrade: %s", adapter.ErrorDetail(err))
+		return 1
+	}
+	fmt.Print(diff.String())
+	return 0
+}
+
+func loadRenderInputs(command string, args []string, stderrLogger *log.Logger) (serviceadapter.GenerateManifestParams, bool) {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	inputs := adapter.RenderInputs{}
+	flags.StringVar(&inputs.PlanPath, "plan", "", "plan YAML, as in the broker's service catalog")
+	flags.StringVar(&inputs.ServiceDeploymentPath, "service-deployment", "", "service deployment YAML with deployment_name, releases and stemcells")
+	flags.StringVar(&inputs.PreviousManifestPath, "previous-manifest", "", "manifest of the deployment being updated")
+	flags.StringVar(&inputs.PreviousPlanPath, "previous-plan", "", "plan YAML the deployment is being updated from")
+	flags.StringVar(&inputs.ParametersPath, "params", "", "JSON file with the arbitrary parameters passed to cf create-service -c")
+	flags.StringVar(&inputs.PlanID, "plan-id", "", "plan ID passed in the request parameters")
+	if err := flags.Parse(args); err != nil {
+		return serviceadapter.GenerateManifestParams{}, false
+	}
+
+	if inputs.PlanPath == "" || inputs.ServiceDeploymentPath == "" {
+		stderrLogger.Printf("%s: -plan and -service-deployment are required", command)
+		return serviceadapter.GenerateManifestParams{}, false
+	}
+
+	params, err := adapter.LoadRenderInputs(inputs)
+	if err != nil {
+		stderrLogger.Printf("%s: %s", command, err)
+		return serviceadapter.GenerateManifestParams{}, false
+	}
+	return params, true
+}
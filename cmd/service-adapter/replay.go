@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/adapter"
+)
+
+func runReplay(args []string, stderrLogger *log.Logger) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	binary := flags.String("binary", "", "adapter binary to replay against, defaults to this one")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() == 0 {
+		stderrLogger.Println("replay: pass recording files or directories to replay")
+		return 1
+	}
+
+	if *binary == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			stderrLogger.Printf("replay: %s", err)
+			return 1
+		}
+		*binary = executable
+	}
+
+	recordings, err := adapter.LoadRecordings(flags.Args()...)
+	if err != nil {
+		stderrLogger.Printf("replay: %s", err)
+		return 1
+	}
+
+	changed, skipped := 0, 0
+	for _, recording := range recordings {
+		if !recording.Replayable() {
+			fmt.Printf("%s (%s): skipped, the operation has side effects\n", recording.Path, strings.Join(recording.Args, " "))
+			skipped++
+			continue
+		}
+		result, err := adapter.Replay(recording, binaryRunner(*binary))
+		if err != nil {
+			stderrLogger.Printf("replay: %s: %s", recording.Path, err)
+			return 1
+		}
+		fmt.Printf("%s (%s): %s", recording.Path, strings.Join(recording.Args, " "), result.String())
+		if !result.Matches() {
+			changed++
+		}
+	}
+	fmt.Printf("%d recordings replayed, %d changed, %d skipped\n", len(recordings)-skipped, changed, skipped)
+	if changed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// binaryRunner runs the adapter at path without recording or auditing the
+// replayed invocations.
+func binaryRunner(path string) adapter.InvocationRunner {
+	return func(args []string, stdin string) (string, int, error) {
+		cmd := exec.Command(path, args...)
+		cmd.Env = append(os.Environ(),
+			adapter.ConfigOverrideEnvPrefix+"RECORDING_DIR=",
+			adapter.ConfigOverrideEnvPrefix+"AUDIT_LOG_PATH=",
+		)
+		cmd.Stdin = strings.NewReader(stdin)
+		stdout := &bytes.Buffer{}
+		cmd.Stdout = stdout
+
+		err := cmd.Run()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return stdout.String(), exitErr.ExitCode(), nil
+		}
+		return stdout.String(), 0, err
+	}
+}
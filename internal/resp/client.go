@@ -0,0 +1,186 @@
+package resp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	DefaultDialTimeout = 5 * time.Second
+	DefaultIOTimeout   = 5 * time.Second
+	DefaultRetryDelay  = 500 * time.Millisecond
+)
+
+type Options struct {
+	Address  string
+	Username string
+	Password string
+
+	// TLS connections verify the server against CACert, a PEM bundle, and
+	// ServerName, which defaults to the host in Address.
+	TLS        bool
+	CACert     string
+	ServerName string
+
+	// Protocol is 2 or 3. RESP3 is negotiated with HELLO, which also
+	// authenticates.
+	Protocol int
+
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+
+	// Retries is how many more times to try connecting when the instance
+	// cannot be reached. Authentication failures are not retried.
+	Retries    int
+	RetryDelay time.Duration
+}
+
+type Client struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	ioTimeout time.Duration
+}
+
+func Dial(opts Options) (*Client, error) {
+	retryDelay := opts.RetryDelay
+	if retryDelay == 0 {
+		retryDelay = DefaultRetryDelay
+	}
+
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryDelay)
+		}
+
+		var client *Client
+		client, err = dialOnce(opts)
+		if err == nil {
+			return client, nil
+		}
+		var serverErr ServerError
+		if errors.As(err, &serverErr) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func dialOnce(opts Options) (*Client, error) {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	ioTimeout := opts.IOTimeout
+	if ioTimeout == 0 {
+		ioTimeout = DefaultIOTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		tlsConfig, tlsErr := tlsConfigFor(opts)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", opts.Address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", opts.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %w", opts.Address, err)
+	}
+
+	client := &Client{conn: conn, reader: bufio.NewReader(conn), ioTimeout: ioTimeout}
+	if err := client.handshake(opts); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func tlsConfigFor(opts Options) (*tls.Config, error) {
+	serverName := opts.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(opts.Address)
+		if err != nil {
+			return nil, err
+		}
+		serverName = host
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if opts.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(opts.CACert)) {
+			return nil, errors.New("could not parse the instance CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func (c *Client) handshake(opts Options) error {
+	username := opts.Username
+	if username == "" {
+		username = "default"
+	}
+
+	switch opts.Protocol {
+	case 0, 2:
+		if opts.Password == "" {
+			return nil
+		}
+		var err error
+		if opts.Username == "" {
+			_, err = c.Do("AUTH", opts.Password)
+		} else {
+			_, err = c.Do("AUTH", opts.Username, opts.Password)
+		}
+		return err
+	case 3:
+		args := []string{"HELLO", "3"}
+		if opts.Password != "" {
+			args = append(args, "AUTH", username, opts.Password)
+		}
+		_, err := c.Do(args...)
+		return err
+	default:
+		return fmt.Errorf("unsupported RESP protocol version %d", opts.Protocol)
+	}
+}
+
+// Do sends a command and returns its reply. Error replies are returned as a
+// ServerError.
+func (c *Client) Do(args ...string) (Value, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.ioTimeout)); err != nil {
+		return Value{}, err
+	}
+	if err := WriteCommand(c.conn, args...); err != nil {
+		return Value{}, err
+	}
+
+	for {
+		reply, err := ReadValue(c.reader)
+		if err != nil {
+			return Value{}, err
+		}
+		switch reply.Kind {
+		case Push:
+			continue
+		case ErrorReply, BlobError:
+			return Value{}, ServerError{Message: reply.Str}
+		}
+		return reply, nil
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,103 @@
+package resp_test
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp/resptest"
+)
+
+var _ = Describe("Client", func() {
+	var server *resptest.Server
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("over plain TCP", func() {
+		BeforeEach(func() {
+			server = resptest.NewServer("admin-password")
+		})
+
+		It("authenticates and runs commands", func() {
+			client, err := resp.Dial(resp.Options{Address: server.Addr(), Password: "admin-password"})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			reply, err := client.Do("PING")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply.Str).To(Equal("PONG"))
+			Expect(server.Commands()).To(Equal([]string{"AUTH", "PING"}))
+		})
+
+		It("authenticates ACL users", func() {
+			server.AddUser("binding-user", "binding-password")
+			client, err := resp.Dial(resp.Options{Address: server.Addr(), Username: "binding-user", Password: "binding-password"})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			reply, err := client.Do("ACL", "WHOAMI")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply.Str).To(Equal("binding-user"))
+		})
+
+		It("negotiates RESP3 with HELLO", func() {
+			client, err := resp.Dial(resp.Options{Address: server.Addr(), Password: "admin-password", Protocol: 3})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			reply, err := client.Do("GET", "missing")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply.Kind).To(Equal(resp.Null))
+			Expect(server.Commands()).To(Equal([]string{"HELLO", "GET"}))
+		})
+
+		It("returns error replies as server errors", func() {
+			_, err := resp.Dial(resp.Options{Address: server.Addr(), Password: "wrong"})
+			Expect(err).To(BeAssignableToTypeOf(resp.ServerError{}))
+			Expect(err.(resp.ServerError).Prefix()).To(Equal("WRONGPASS"))
+		})
+
+		It("does not retry authentication failures", func() {
+			_, err := resp.Dial(resp.Options{Address: server.Addr(), Password: "wrong", Retries: 3, RetryDelay: time.Millisecond})
+			Expect(err).To(HaveOccurred())
+			Expect(server.Commands()).To(Equal([]string{"AUTH"}))
+		})
+	})
+
+	Context("over TLS", func() {
+		BeforeEach(func() {
+			server = resptest.NewTLSServer("admin-password")
+		})
+
+		It("verifies the server with the instance CA", func() {
+			client, err := resp.Dial(resp.Options{Address: server.Addr(), Password: "admin-password", TLS: true, CACert: server.CACert})
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			_, err = client.Do("PING")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("refuses servers not signed by the CA", func() {
+			_, err := resp.Dial(resp.Options{Address: server.Addr(), Password: "admin-password", TLS: true})
+			Expect(err).To(MatchError(ContainSubstring("certificate")))
+		})
+	})
+
+	It("retries connecting and reports the last error", func() {
+		server = resptest.NewServer("")
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		address := listener.Addr().String()
+		listener.Close()
+
+		start := time.Now()
+		_, err = resp.Dial(resp.Options{Address: address, Retries: 2, RetryDelay: 20 * time.Millisecond})
+		Expect(err).To(MatchError(ContainSubstring("could not connect to " + address)))
+		Expect(time.Since(start)).To(BeNumerically(">=", 40*time.Millisecond))
+	})
+})
@@ -0,0 +1,250 @@
+// Package resp implements enough of the Redis serialization protocol (RESP2
+// and RESP3) for the adapter to run commands against service instances.
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Kind byte
+
+const (
+	SimpleString   Kind = '+'
+	ErrorReply     Kind = '-'
+	Integer        Kind = ':'
+	BulkString     Kind = '$'
+	Array          Kind = '*'
+	Null           Kind = '_'
+	Double         Kind = ','
+	Boolean        Kind = '#'
+	BlobError      Kind = '!'
+	VerbatimString Kind = '='
+	BigNumber      Kind = '('
+	Map            Kind = '%'
+	Set            Kind = '~'
+	Attribute      Kind = '|'
+	Push           Kind = '>'
+)
+
+// Limits on replies, so that a misbehaving server cannot make the adapter
+// allocate unbounded memory. The adapter only reads short replies.
+const (
+	MaxLineLength      = 64 << 10
+	MaxBulkLength      = 16 << 20
+	MaxAggregateLength = 1 << 20
+	MaxNestingDepth    = 32
+)
+
+// Value is a decoded RESP reply. Str holds the payload of string-like, error,
+// double and big number replies; Int holds integers and booleans (0 or 1);
+// Elems holds aggregate replies, with maps flattened to key, value pairs.
+type Value struct {
+	Kind  Kind
+	Str   string
+	Int   int64
+	Elems []Value
+	IsNil bool
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case Integer, Boolean:
+		return strconv.FormatInt(v.Int, 10)
+	case Array, Map, Set, Push:
+		parts := make([]string, len(v.Elems))
+		for i, elem := range v.Elems {
+			parts[i] = elem.String()
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	default:
+		return v.Str
+	}
+}
+
+// ServerError is an error reply sent by Redis, e.g. "WRONGPASS invalid
+// username-password pair".
+type ServerError struct {
+	Message string
+}
+
+func (e ServerError) Error() string {
+	return e.Message
+}
+
+// Prefix returns the error code, e.g. "WRONGPASS" or "NOPERM".
+func (e ServerError) Prefix() string {
+	return strings.SplitN(e.Message, " ", 2)[0]
+}
+
+func WriteCommand(w io.Writer, args ...string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func WriteValue(w io.Writer, v Value) error {
+	_, err := io.WriteString(w, encode(v))
+	return err
+}
+
+func encode(v Value) string {
+	switch v.Kind {
+	case SimpleString, ErrorReply, Double, BigNumber:
+		return string(v.Kind) + v.Str + "\r\n"
+	case Integer:
+		return ":" + strconv.FormatInt(v.Int, 10) + "\r\n"
+	case Boolean:
+		if v.Int != 0 {
+			return "#t\r\n"
+		}
+		return "#f\r\n"
+	case Null:
+		return "_\r\n"
+	case BulkString, BlobError, VerbatimString:
+		if v.IsNil {
+			return "$-1\r\n"
+		}
+		return string(v.Kind) + strconv.Itoa(len(v.Str)) + "\r\n" + v.Str + "\r\n"
+	case Array, Set, Push, Map, Attribute:
+		if v.IsNil {
+			return "*-1\r\n"
+		}
+		count := len(v.Elems)
+		if v.Kind == Map || v.Kind == Attribute {
+			count /= 2
+		}
+		var sb strings.Builder
+		sb.WriteString(string(v.Kind) + strconv.Itoa(count) + "\r\n")
+		for _, elem := range v.Elems {
+			sb.WriteString(encode(elem))
+		}
+		return sb.String()
+	}
+	return "-ERR unknown reply type\r\n"
+}
+
+// ReadValue reads one reply. Attribute replies are skipped, since the adapter
+// has no use for them.
+func ReadValue(r *bufio.Reader) (Value, error) {
+	return readValue(r, 0)
+}
+
+func readValue(r *bufio.Reader, depth int) (Value, error) {
+	if depth > MaxNestingDepth {
+		return Value{}, fmt.Errorf("resp: reply nested more than %d levels deep", MaxNestingDepth)
+	}
+	line, err := readLine(r)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, fmt.Errorf("resp: empty reply line")
+	}
+
+	kind, payload := Kind(line[0]), line[1:]
+	switch kind {
+	case SimpleString, ErrorReply, Double, BigNumber:
+		return Value{Kind: kind, Str: payload}, nil
+	case Integer:
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("resp: invalid integer %q", payload)
+		}
+		return Value{Kind: kind, Int: n}, nil
+	case Boolean:
+		switch payload {
+		case "t":
+			return Value{Kind: kind, Int: 1}, nil
+		case "f":
+			return Value{Kind: kind}, nil
+		}
+		return Value{}, fmt.Errorf("resp: invalid boolean %q", payload)
+	case Null:
+		return Value{Kind: kind, IsNil: true}, nil
+	case BulkString, BlobError, VerbatimString:
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return Value{}, fmt.Errorf("resp: invalid length %q", payload)
+		}
+		if length < 0 {
+			return Value{Kind: kind, IsNil: true}, nil
+		}
+		if length > MaxBulkLength {
+			return Value{}, fmt.Errorf("resp: bulk length %d exceeds %d", length, MaxBulkLength)
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return Value{}, err
+		}
+		if string(data[length:]) != "\r\n" {
+			return Value{}, fmt.Errorf("resp: bulk string of length %d not terminated by CRLF", length)
+		}
+		return Value{Kind: kind, Str: string(data[:length])}, nil
+	case Array, Set, Push, Map, Attribute:
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return Value{}, fmt.Errorf("resp: invalid length %q", payload)
+		}
+		if count < 0 {
+			return Value{Kind: kind, IsNil: true}, nil
+		}
+		if count > MaxAggregateLength {
+			return Value{}, fmt.Errorf("resp: aggregate length %d exceeds %d", count, MaxAggregateLength)
+		}
+		if kind == Map || kind == Attribute {
+			count *= 2
+		}
+		// the elements are appended as they arrive rather than allocated up
+		// front, so a large count alone costs nothing
+		var elems []Value
+		for i := 0; i < count; i++ {
+			elem, err := readValue(r, depth+1)
+			if err != nil {
+				return Value{}, err
+			}
+			elems = append(elems, elem)
+		}
+		if kind == Attribute {
+			return readValue(r, depth)
+		}
+		return Value{Kind: kind, Elems: elems}, nil
+	}
+	return Value{}, fmt.Errorf("resp: unknown reply type %q", line[0])
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	var buf []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(buf)+len(chunk) > MaxLineLength+2 {
+			return "", fmt.Errorf("resp: line exceeds %d bytes", MaxLineLength)
+		}
+		buf = append(buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+	line := string(buf)
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", fmt.Errorf("resp: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,74 @@
+package resp_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp"
+)
+
+var _ = Describe("Protocol", func() {
+	It("encodes commands as arrays of bulk strings", func() {
+		buf := &bytes.Buffer{}
+		Expect(resp.WriteCommand(buf, "AUTH", "p@ss")).To(Succeed())
+		Expect(buf.String()).To(Equal("*2\r\n$4\r\nAUTH\r\n$4\r\np@ss\r\n"))
+	})
+
+	DescribeTable("decoding replies",
+		func(encoded string, expected resp.Value) {
+			value, err := resp.ReadValue(bufio.NewReader(strings.NewReader(encoded)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(expected))
+		},
+		Entry("simple string", "+OK\r\n", resp.Value{Kind: resp.SimpleString, Str: "OK"}),
+		Entry("error", "-WRONGPASS nope\r\n", resp.Value{Kind: resp.ErrorReply, Str: "WRONGPASS nope"}),
+		Entry("integer", ":42\r\n", resp.Value{Kind: resp.Integer, Int: 42}),
+		Entry("bulk string", "$5\r\nhe\r\nl\r\n", resp.Value{Kind: resp.BulkString, Str: "he\r\nl"}),
+		Entry("nil bulk string", "$-1\r\n", resp.Value{Kind: resp.BulkString, IsNil: true}),
+		Entry("array", "*2\r\n:1\r\n+two\r\n", resp.Value{Kind: resp.Array, Elems: []resp.Value{
+			{Kind: resp.Integer, Int: 1}, {Kind: resp.SimpleString, Str: "two"},
+		}}),
+		Entry("RESP3 null", "_\r\n", resp.Value{Kind: resp.Null, IsNil: true}),
+		Entry("RESP3 boolean", "#t\r\n", resp.Value{Kind: resp.Boolean, Int: 1}),
+		Entry("RESP3 double", ",3.14\r\n", resp.Value{Kind: resp.Double, Str: "3.14"}),
+		Entry("RESP3 map", "%1\r\n+proto\r\n:3\r\n", resp.Value{Kind: resp.Map, Elems: []resp.Value{
+			{Kind: resp.SimpleString, Str: "proto"}, {Kind: resp.Integer, Int: 3},
+		}}),
+		Entry("RESP3 attribute followed by a reply", "|1\r\n+ttl\r\n:3\r\n+OK\r\n", resp.Value{Kind: resp.SimpleString, Str: "OK"}),
+	)
+
+	It("round trips values it encodes", func() {
+		original := resp.Value{Kind: resp.Map, Elems: []resp.Value{
+			{Kind: resp.BulkString, Str: "users"},
+			{Kind: resp.Set, Elems: []resp.Value{{Kind: resp.BulkString, Str: "default"}}},
+		}}
+		buf := &bytes.Buffer{}
+		Expect(resp.WriteValue(buf, original)).To(Succeed())
+
+		decoded, err := resp.ReadValue(bufio.NewReader(buf))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded).To(Equal(original))
+	})
+
+	DescribeTable("rejecting malformed or oversized replies",
+		func(reply, expectedErr string) {
+			_, err := resp.ReadValue(bufio.NewReader(strings.NewReader(reply)))
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("huge bulk strings", "$9999999999\r\n", "resp: bulk length 9999999999 exceeds 16777216"),
+		Entry("huge aggregates", "*9999999999\r\n", "resp: aggregate length 9999999999 exceeds 1048576"),
+		Entry("aggregates with fewer elements than announced", "*1048576\r\n:1\r\n", "EOF"),
+		Entry("overlong lines", "+"+strings.Repeat("a", 70000)+"\r\n", "resp: line exceeds 65536 bytes"),
+		Entry("bulk strings without CRLF", "$2\r\nOKxx", "resp: bulk string of length 2 not terminated by CRLF"),
+		Entry("deeply nested aggregates", strings.Repeat("*1\r\n", 40)+":1\r\n", "resp: reply nested more than 32 levels deep"),
+	)
+
+	It("rejects unknown reply types", func() {
+		_, err := resp.ReadValue(bufio.NewReader(strings.NewReader("?what\r\n")))
+		Expect(err).To(MatchError(`resp: unknown reply type '?'`))
+	})
+})
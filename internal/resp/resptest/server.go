@@ -0,0 +1,467 @@
+// Package resptest provides an in-process fake Redis server that understands
+// the handful of commands the adapter uses, so tests need no real Redis.
+package resptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf-experimental/redis-example-service-adapter/internal/resp"
+)
+
+type Server struct {
+	// CACert is the PEM encoded certificate the TLS server is signed with.
+	CACert string
+
+	listener net.Listener
+	mu       sync.Mutex
+	password string
+	users    map[string]string
+	rules    map[string][]string
+	saved    []string
+	dryRuns  []string
+	data     map[string]string
+	commands []string
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a fake Redis listening on a random local port. The default
+// user requires password, unless it is empty.
+func NewServer(password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	return start(listener, password, "")
+}
+
+// NewServerAt is like NewServer but listens on address, e.g. to run several
+// fake Redis nodes on the same port of different loopback addresses.
+func NewServerAt(address, password string) *Server {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		panic(err)
+	}
+	return start(listener, password, "")
+}
+
+// NewTLSServer is like NewServer but only accepts TLS connections, using a
+// self-signed certificate valid for 127.0.0.1 and localhost.
+func NewTLSServer(password string) *Server {
+	cert, caPEM := selfSignedCertificate()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		panic(err)
+	}
+	return start(listener, password, caPEM)
+}
+
+func start(listener net.Listener, password, caCert string) *Server {
+	s := &Server{
+		CACert:   caCert,
+		listener: listener,
+		password: password,
+		users:    map[string]string{},
+		rules:    map[string][]string{},
+		data:     map[string]string{},
+		conns:    map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Users returns the ACL users other than default.
+func (s *Server) Users() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var users []string
+	for user := range s.users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// SavedUsers returns the ACL users other than default as of the last ACL
+// SAVE.
+func (s *Server) SavedUsers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.saved...)
+}
+
+// DryRuns returns the names of the commands checked with ACL DRYRUN so far.
+func (s *Server) DryRuns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.dryRuns...)
+}
+
+// Rules returns the rules of the last ACL SETUSER of username.
+func (s *Server) Rules(username string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.rules[username]...)
+}
+
+func (s *Server) AddUser(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[username] = password
+}
+
+// Commands returns the names of the commands received so far.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+type session struct {
+	user     string
+	protocol int
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	sess := &session{protocol: 2}
+	if s.password == "" {
+		sess.user = "default"
+	}
+
+	for {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		command, err := resp.ReadValue(reader)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, elem := range command.Elems {
+			args = append(args, elem.Str)
+		}
+		if len(args) == 0 {
+			resp.WriteValue(conn, errorReply("ERR empty command"))
+			continue
+		}
+
+		reply, quit := s.execute(sess, args)
+		resp.WriteValue(conn, reply)
+		if quit {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(sess *session, args []string) (resp.Value, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToUpper(args[0])
+	s.commands = append(s.commands, name)
+
+	switch name {
+	case "AUTH":
+		return s.auth(sess, args[1:]), false
+	case "HELLO":
+		return s.hello(sess, args[1:]), false
+	case "QUIT":
+		return ok(), true
+	}
+
+	if sess.user == "" {
+		return errorReply("NOAUTH Authentication required."), false
+	}
+
+	if s.denied(sess.user, name) {
+		return errorReply("NOPERM this user has no permissions to run the '" + strings.ToLower(name) + "' command"), false
+	}
+
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			return bulk(args[1]), false
+		}
+		return resp.Value{Kind: resp.SimpleString, Str: "PONG"}, false
+	case "ECHO":
+		if len(args) != 2 {
+			return wrongArgs(name), false
+		}
+		return bulk(args[1]), false
+	case "SET":
+		if len(args) < 3 {
+			return wrongArgs(name), false
+		}
+		s.data[args[1]] = args[2]
+		return ok(), false
+	case "GET":
+		if len(args) != 2 {
+			return wrongArgs(name), false
+		}
+		value, found := s.data[args[1]]
+		if !found {
+			return nilReply(sess), false
+		}
+		return bulk(value), false
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, found := s.data[key]; found {
+				delete(s.data, key)
+				deleted++
+			}
+		}
+		return resp.Value{Kind: resp.Integer, Int: int64(deleted)}, false
+	case "ACL":
+		return s.acl(sess, args[1:]), false
+	}
+	return errorReply("ERR unknown command '" + args[0] + "'"), false
+}
+
+func (s *Server) auth(sess *session, args []string) resp.Value {
+	var username, password string
+	switch len(args) {
+	case 1:
+		username, password = "default", args[0]
+	case 2:
+		username, password = args[0], args[1]
+	default:
+		return wrongArgs("AUTH")
+	}
+	if !s.validCredentials(username, password) {
+		return errorReply("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	sess.user = username
+	return ok()
+}
+
+func (s *Server) hello(sess *session, args []string) resp.Value {
+	if len(args) > 0 {
+		switch args[0] {
+		case "2":
+			sess.protocol = 2
+		case "3":
+			sess.protocol = 3
+		default:
+			return errorReply("NOPROTO unsupported protocol version")
+		}
+	}
+	if len(args) >= 4 && strings.ToUpper(args[1]) == "AUTH" {
+		if reply := s.auth(sess, args[2:4]); reply.Kind == resp.ErrorReply {
+			return reply
+		}
+	}
+	if sess.user == "" {
+		return errorReply("NOAUTH HELLO must be called with the client already authenticated")
+	}
+
+	fields := []resp.Value{
+		bulk("server"), bulk("redis"),
+		bulk("version"), bulk("7.2.0"),
+		bulk("proto"), {Kind: resp.Integer, Int: int64(sess.protocol)},
+	}
+	if sess.protocol == 3 {
+		return resp.Value{Kind: resp.Map, Elems: fields}
+	}
+	return resp.Value{Kind: resp.Array, Elems: fields}
+}
+
+func (s *Server) acl(sess *session, args []string) resp.Value {
+	if len(args) == 0 {
+		return wrongArgs("ACL")
+	}
+	if sess.user != "default" && strings.ToUpper(args[0]) != "WHOAMI" {
+		return errorReply("NOPERM this user has no permissions to run the 'acl' command")
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "WHOAMI":
+		return bulk(sess.user)
+	case "SETUSER":
+		if len(args) < 2 {
+			return wrongArgs("ACL SETUSER")
+		}
+		password := s.users[args[1]]
+		for _, rule := range args[2:] {
+			if strings.HasPrefix(rule, ">") {
+				password = rule[1:]
+			}
+		}
+		s.users[args[1]] = password
+		s.rules[args[1]] = append([]string{}, args[2:]...)
+		return ok()
+	case "GETUSER":
+		if len(args) != 2 {
+			return wrongArgs("ACL GETUSER")
+		}
+		if _, found := s.users[args[1]]; !found && args[1] != "default" {
+			return nilReply(sess)
+		}
+		return resp.Value{Kind: resp.Array, Elems: []resp.Value{
+			bulk("flags"), {Kind: resp.Array, Elems: []resp.Value{bulk("on")}},
+		}}
+	case "DELUSER":
+		deleted := 0
+		for _, user := range args[1:] {
+			if _, found := s.users[user]; found {
+				delete(s.users, user)
+				delete(s.rules, user)
+				deleted++
+			}
+		}
+		return resp.Value{Kind: resp.Integer, Int: int64(deleted)}
+	case "LIST":
+		list := []resp.Value{bulk("user default on")}
+		for user := range s.users {
+			list = append(list, bulk("user "+user+" on"))
+		}
+		return resp.Value{Kind: resp.Array, Elems: list}
+	case "DRYRUN":
+		if len(args) < 3 {
+			return wrongArgs("ACL DRYRUN")
+		}
+		if _, found := s.users[args[1]]; !found {
+			return errorReply("ERR User '" + args[1] + "' not found")
+		}
+		command := strings.ToUpper(args[2])
+		s.dryRuns = append(s.dryRuns, command)
+		if s.denied(args[1], command) {
+			return bulk("This user has no permissions to run the '" + strings.ToLower(command) + "' command")
+		}
+		return ok()
+	case "SAVE":
+		s.saved = nil
+		for user := range s.users {
+			s.saved = append(s.saved, user)
+		}
+		sort.Strings(s.saved)
+		return ok()
+	}
+	return errorReply("ERR unknown subcommand '" + args[0] + "'")
+}
+
+// denied reports whether the ACL rules of user remove command, e.g. -set.
+// Only single commands are understood, not categories.
+func (s *Server) denied(user, command string) bool {
+	for _, rule := range s.rules[user] {
+		if strings.EqualFold(rule, "-"+command) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) validCredentials(username, password string) bool {
+	if username == "default" {
+		return s.password == "" || password == s.password
+	}
+	expected, found := s.users[username]
+	return found && password == expected
+}
+
+func ok() resp.Value {
+	return resp.Value{Kind: resp.SimpleString, Str: "OK"}
+}
+
+func bulk(s string) resp.Value {
+	return resp.Value{Kind: resp.BulkString, Str: s}
+}
+
+func nilReply(sess *session) resp.Value {
+	if sess.protocol == 3 {
+		return resp.Value{Kind: resp.Null, IsNil: true}
+	}
+	return resp.Value{Kind: resp.BulkString, IsNil: true}
+}
+
+func errorReply(message string) resp.Value {
+	return resp.Value{Kind: resp.ErrorReply, Str: message}
+}
+
+func wrongArgs(command string) resp.Value {
+	return errorReply("ERR wrong number of arguments for '" + strings.ToLower(command) + "' command")
+}
+
+func selfSignedCertificate() (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "resptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}